@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/bondzai/grextor/internal/config"
+)
+
+// runCollection prints the changes EnsureCollection would make to the
+// configured collection and applies them with --apply. The vector size is
+// taken from --dims (embedding.dimensions), so no embedder is needed.
+func runCollection(args []string) {
+	fs := flag.NewFlagSet("collection", flag.ExitOnError)
+	cfgFlags := config.RegisterFlags(fs)
+	apply := fs.Bool("apply", false, "Apply the changes instead of only listing them")
+	fs.Parse(args)
+
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if cfg.Embedding.Dimensions == 0 {
+		log.Fatal("Please set the vector size using --dims or embedding.dimensions")
+	}
+	log.Printf("Using %s", cfg)
+
+	ctx := context.Background()
+	store, err := cfg.NewVectorStore(uint64(cfg.Embedding.Dimensions))
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.HealthCheck(ctx); err != nil {
+		log.Fatalf("Failed to reach Qdrant: %v", err)
+	}
+
+	changes, err := store.DiffCollection(ctx)
+	if err != nil {
+		log.Fatalf("Failed to compare collection: %v", err)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("Collection %s matches the configuration\n", store.CollectionName())
+		return
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if !*apply {
+		fmt.Println("Run with --apply to update the collection.")
+		return
+	}
+	if err := store.EnsureCollection(ctx); err != nil {
+		log.Fatalf("Failed to update collection: %v", err)
+	}
+	fmt.Printf("Updated collection %s\n", store.CollectionName())
+}
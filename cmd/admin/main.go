@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: grextor-admin <command> [flags]
+
+Commands:
+  reembed   Re-embed every stored document into a new collection and switch
+            the configured collection alias to it
+  collection
+            Show how the collection differs from the configured spec
+            (HNSW, on-disk storage, quantization, payload indexes) and
+            optionally apply the changes
+
+Run 'grextor-admin <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "reembed":
+		runReembed(os.Args[2:])
+	case "collection":
+		runCollection(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bondzai/grextor/internal/config"
+	"github.com/bondzai/grextor/internal/embed"
+	"github.com/bondzai/grextor/internal/engine"
+	"github.com/bondzai/grextor/internal/vector"
+)
+
+// reembedState is persisted after every batch so an interrupted run can
+// resume where it stopped.
+type reembedState struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+	Offset     string `json:"offset"`
+	Done       int    `json:"done"`
+	Complete   bool   `json:"complete"`
+}
+
+// runReembed copies every point of the configured collection into a new
+// collection, re-embedding its "content" and field payloads with the target
+// embedder and keeping its payload, and then atomically points the configured name (used as a Qdrant
+// alias) at the new collection. Documents ingested while the copy runs may
+// be missed; pause ingestion or re-run afterwards.
+func runReembed(args []string) {
+	fs := flag.NewFlagSet("reembed", flag.ExitOnError)
+	cfgFlags := config.RegisterFlags(fs)
+	var (
+		toProvider   = fs.String("to-provider", "", "Embedding provider for the new collection (default: current)")
+		toModel      = fs.String("to-model", "", "Embedding model for the new collection")
+		toDims       = fs.Int("to-dims", 0, "Expected vector size of the new model (0 = detect)")
+		target       = fs.String("target-collection", "", "Name of the new collection (default: <collection>_<model>)")
+		batchSize    = fs.Int("batch-size", 64, "Points re-embedded per batch")
+		stateFile    = fs.String("state-file", "", "Progress file used to resume (default: reembed-<target>.json)")
+		deleteSource = fs.Bool("delete-source", false, "Drop the source collection when it is not behind an alias yet, so the alias can take its name")
+	)
+	fs.Parse(args)
+
+	if *toModel == "" {
+		log.Fatal("Please provide the new model using --to-model")
+	}
+
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	log.Printf("Using %s", cfg)
+
+	ctx := context.Background()
+	alias := cfg.CollectionName()
+
+	// 1. Setup target embedder
+	targetCfg := *cfg
+	targetCfg.Embedding.Model = *toModel
+	targetCfg.Embedding.Dimensions = *toDims
+	if *toProvider != "" {
+		targetCfg.Embedding.Provider = *toProvider
+	}
+	embedder, err := targetCfg.NewEmbedder()
+	if err != nil {
+		log.Fatalf("Failed to set up embedder: %v", err)
+	}
+	if closer, ok := embedder.(io.Closer); ok {
+		defer closer.Close()
+	}
+	dims, err := targetCfg.VectorSize(ctx, embedder)
+	if err != nil {
+		log.Fatalf("Failed to determine vector size: %v", err)
+	}
+
+	// 2. Resolve the collection currently behind the alias
+	source, err := cfg.NewVectorStore(0)
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+	defer source.Close()
+	if _, err := source.HealthCheck(ctx); err != nil {
+		log.Fatalf("Failed to reach Qdrant: %v", err)
+	}
+
+	sourceName, isAlias, err := source.ResolveAlias(ctx, alias)
+	if err != nil {
+		log.Fatalf("Failed to resolve %s: %v", alias, err)
+	}
+	if *target == "" {
+		*target = alias + "_" + sanitizeName(*toModel)
+	}
+	if *target == sourceName {
+		log.Fatalf("Collection %s is already active for %s", *target, alias)
+	}
+	if *stateFile == "" {
+		*stateFile = "reembed-" + *target + ".json"
+	}
+
+	state, err := loadReembedState(*stateFile)
+	if err != nil {
+		log.Fatalf("Failed to load state: %v", err)
+	}
+	want := reembedState{
+		Source:     sourceName,
+		Target:     *target,
+		Provider:   targetCfg.EmbeddingProvider(),
+		Model:      *toModel,
+		Dimensions: int(dims),
+	}
+	if state == nil {
+		state = &want
+	} else if state.Source != want.Source || state.Target != want.Target || state.Provider != want.Provider ||
+		state.Model != want.Model || state.Dimensions != want.Dimensions {
+		log.Fatalf("State file %s belongs to a different migration (%s -> %s, %s model %s with %d dimensions)",
+			*stateFile, state.Source, state.Target, state.Provider, state.Model, state.Dimensions)
+	} else {
+		log.Printf("Resuming after %d documents", state.Done)
+	}
+
+	// 3. Setup target collection
+	reader, err := vector.NewQdrantStore(cfg.Qdrant.Addr, sourceName, 0, cfg.ConnOptions()...)
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+	defer reader.Close()
+
+	writer, err := vector.NewQdrantStore(cfg.Qdrant.Addr, *target, dims, cfg.QdrantOptions()...)
+	if err != nil {
+		log.Fatalf("Failed to connect to Qdrant: %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.EnsureCollection(ctx); err != nil {
+		log.Fatalf("Failed to ensure collection %s: %v", *target, err)
+	}
+
+	// 4. Copy in resumable batches
+	start := time.Now()
+	for !state.Complete {
+		if err := reembedBatch(ctx, reader, writer, embedder, state, *batchSize); err != nil {
+			log.Fatalf("Re-embedding failed after %d documents: %v (re-run to resume)", state.Done, err)
+		}
+		if err := saveReembedState(*stateFile, state); err != nil {
+			log.Fatalf("Failed to save state: %v", err)
+		}
+		log.Printf("Re-embedded %d documents", state.Done)
+	}
+
+	// 5. Switch the alias
+	if !isAlias {
+		if !*deleteSource {
+			log.Fatalf("%s is a collection, not an alias. Re-run with --delete-source to drop it and "+
+				"create the alias (search is unavailable for a moment), or point clients at %s directly.", alias, *target)
+		}
+		log.Printf("Dropping collection %s to replace it with an alias", alias)
+		if err := reader.DeleteCollection(ctx, alias); err != nil {
+			log.Fatalf("Failed to drop %s: %v", alias, err)
+		}
+	}
+	if err := writer.SwitchAlias(ctx, alias, *target); err != nil {
+		log.Fatalf("Failed to switch alias: %v", err)
+	}
+
+	fmt.Printf("Re-embedded %d documents into %s and pointed %s at it (took %v)\n",
+		state.Done, *target, alias, time.Since(start))
+	if isAlias {
+		fmt.Printf("Previous collection %s was kept; drop it once the new model is verified.\n", sourceName)
+	}
+}
+
+// reembedBatch processes one page of the source collection and advances state.
+// Every point is copied; one that cannot be re-embedded fails the batch, so
+// that the alias is never switched to a collection missing documents.
+func reembedBatch(ctx context.Context, reader, writer *vector.QdrantStore, embedder embed.Embedder, state *reembedState, batchSize int) error {
+	points, next, err := reader.Scroll(ctx, state.Offset, batchSize)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]*vector.Point, 0, len(points))
+	for _, p := range points {
+		// Documents are embedded from their content even when it is empty, as
+		// on ingestion.
+		content, ok := p.Metadata["content"].(string)
+		if !ok {
+			return fmt.Errorf("point %s has no content payload to re-embed", p.ID)
+		}
+		vec, err := embedder.Embed(ctx, content)
+		if err != nil {
+			return fmt.Errorf("embedding %s: %w", p.ID, err)
+		}
+		p.Vector = vec
+		for key, v := range p.Metadata {
+			name, ok := strings.CutPrefix(key, engine.FieldPayloadPrefix)
+			text, isText := v.(string)
+			if !ok || !isText {
+				continue
+			}
+			fv, err := embedder.Embed(ctx, text)
+			if err != nil {
+				return fmt.Errorf("embedding field %s of %s: %w", name, p.ID, err)
+			}
+			if p.Vectors == nil {
+				p.Vectors = make(map[string][]float32)
+			}
+			p.Vectors[name] = fv
+		}
+		batch = append(batch, p)
+	}
+
+	if len(batch) > 0 {
+		if err := writer.Upsert(ctx, batch); err != nil {
+			return fmt.Errorf("writing batch: %w", err)
+		}
+	}
+
+	state.Done += len(batch)
+	state.Offset = next
+	state.Complete = next == ""
+	return nil
+}
+
+func loadReembedState(path string) (*reembedState, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state reembedState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// saveReembedState writes the state atomically so a crash never leaves a
+// truncated file behind.
+func saveReembedState(path string, state *reembedState) error {
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// sanitizeName turns a model name into a collection name suffix.
+func sanitizeName(s string) string {
+	return strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bondzai/grextor/internal/engine"
+	"github.com/bondzai/grextor/internal/graph"
+)
+
+// maxRecordSize bounds one line of an --input file.
+const maxRecordSize = 64 << 20
+
+// bulkRecord is one line of an --input JSONL file.
+type bulkRecord struct {
+	ID       string                 `json:"id"`
+	Content  string                 `json:"content"`
+	Fields   map[string]string      `json:"fields,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Links are edges from this document to other documents of the input,
+	// such as imports in a dependency graph.
+	Links []bulkLink `json:"links,omitempty"`
+}
+
+type bulkLink struct {
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// bulkResult summarises an --input run.
+type bulkResult struct {
+	engine.IngestStats
+	// Skipped counts the documents stored by a previous run.
+	Skipped int
+	// Invalid counts the lines that could not be parsed.
+	Invalid int
+	Links   int
+	// Failures lists the invalid lines and failed documents.
+	Failures []bulkFailure
+}
+
+// openInput opens path, or stdin for "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// ingestFile streams the JSONL records of r through the engine's ingestion
+// pipeline and then adds their links, once every document exists. Invalid
+// lines and failed documents are logged, skipped and listed in the result.
+// Documents found in cp are skipped, and stored ones are journaled to it.
+func ingestFile(ctx context.Context, eng *engine.Engine, r io.Reader, source string, opts engine.IngestOptions, cp *checkpoint) (bulkResult, error) {
+	var (
+		res      bulkResult
+		edges    []*graph.Edge
+		invalid  []bulkFailure
+		failures []bulkFailure
+		readErr  error
+		cpErr    error
+		docs     = make(chan engine.Document)
+		read     sync.WaitGroup
+	)
+	read.Add(1)
+	go func() {
+		defer read.Done()
+		defer close(docs)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxRecordSize)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			doc, links, err := parseRecord(scanner.Bytes(), source)
+			if err != nil {
+				log.Printf("Skipping line %d: %v", line, err)
+				invalid = append(invalid, bulkFailure{Line: line, Error: err.Error()})
+				continue
+			}
+			// Links are re-added on resume, as the run may have stopped
+			// while linking.
+			edges = append(edges, links...)
+			if cp.Done(doc.ID) {
+				res.Skipped++
+				continue
+			}
+			select {
+			case docs <- doc:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr = scanner.Err()
+	}()
+
+	opts.OnDocument = func(id string, err error) {
+		if err != nil {
+			log.Printf("Failed to ingest %s: %v", id, err)
+			failures = append(failures, bulkFailure{ID: id, Error: err.Error()})
+			return
+		}
+		if err := cp.Add(id); err != nil && cpErr == nil {
+			cpErr = err
+		}
+	}
+	logProgress := progressLogger(10 * time.Second)
+	opts.Progress = func(s engine.IngestStats) {
+		if err := cp.Flush(); err != nil && cpErr == nil {
+			cpErr = err
+		}
+		logProgress(s)
+	}
+
+	stats, err := eng.IngestMany(ctx, docs, opts)
+	read.Wait()
+	res.IngestStats = stats
+	res.Invalid = len(invalid)
+	res.Failures = append(invalid, failures...)
+	if err == nil && cpErr != nil {
+		err = fmt.Errorf("writing checkpoint: %w", cpErr)
+	}
+	if err != nil {
+		return res, err
+	}
+	if readErr != nil {
+		return res, fmt.Errorf("reading input: %w", readErr)
+	}
+
+	if len(edges) > 0 {
+		log.Printf("Linking %d edges...", len(edges))
+		err := eng.Link(ctx, edges)
+		var unmatched *graph.UnmatchedEdgesError
+		if errors.As(err, &unmatched) {
+			for _, edge := range unmatched.Edges {
+				res.Failures = append(res.Failures, bulkFailure{
+					ID:    edge.FromID,
+					Error: fmt.Sprintf("link %s to %s not created: document not found", edge.Type, edge.ToID),
+				})
+			}
+			res.Links = len(edges) - len(unmatched.Edges)
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Links = len(edges)
+	}
+	return res, nil
+}
+
+// parseRecord decodes one line into a document and the edges it links to.
+func parseRecord(data []byte, source string) (engine.Document, []*graph.Edge, error) {
+	var rec bulkRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return engine.Document{}, nil, err
+	}
+	if rec.ID == "" || rec.Content == "" {
+		return engine.Document{}, nil, errors.New("id and content are required")
+	}
+
+	if rec.Metadata == nil {
+		rec.Metadata = make(map[string]interface{})
+	}
+	if _, ok := rec.Metadata["source"]; !ok {
+		rec.Metadata["source"] = source
+	}
+	edges := make([]*graph.Edge, len(rec.Links))
+	for i, l := range rec.Links {
+		edges[i] = &graph.Edge{FromID: rec.ID, ToID: l.To, Type: l.Type}
+	}
+	return engine.Document{ID: rec.ID, Content: rec.Content, Fields: rec.Fields, Metadata: rec.Metadata}, edges, nil
+}
+
+// progressLogger logs the running totals at most once per interval.
+func progressLogger(interval time.Duration) func(engine.IngestStats) {
+	start := time.Now()
+	last := start
+	return func(s engine.IngestStats) {
+		if time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		rate := float64(s.Stored) / time.Since(start).Seconds()
+		log.Printf("Stored %d documents, %d failed (%.0f docs/s)", s.Stored, s.Failed, rate)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// checkpoint is an append-only journal of the IDs of documents stored in
+// both stores, one JSON string per line so that IDs may contain newlines. A
+// resumed run skips them; documents that failed
+// or were still in flight are not journaled and are retried. Lines are
+// flushed after every batch, so a crash loses at most the IDs of a few
+// batches, which are then re-ingested harmlessly.
+type checkpoint struct {
+	f    *os.File
+	w    *bufio.Writer
+	done map[string]bool
+}
+
+// openCheckpoint opens the journal at path. With resume the IDs already in
+// it are loaded and new ones appended; otherwise it is truncated.
+func openCheckpoint(path string, resume bool) (*checkpoint, error) {
+	c := &checkpoint{done: make(map[string]bool)}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	var cutOff bool
+	if resume {
+		var err error
+		if cutOff, err = c.load(path); err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c.f, c.w = f, bufio.NewWriter(f)
+	if cutOff {
+		c.w.WriteString("\n")
+	}
+	return c, nil
+}
+
+// load reads the IDs journaled by a previous run. A last line without a
+// newline was cut off by a crash; it is ignored and reported, so that it can
+// be terminated before the next ID is appended. Once terminated it no longer
+// parses, and is skipped like any other malformed line.
+func (c *checkpoint) load(path string) (cutOff bool, err error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	complete := raw[:bytes.LastIndexByte(raw, '\n')+1]
+	for _, line := range bytes.Split(complete, []byte("\n")) {
+		var id string
+		if json.Unmarshal(line, &id) == nil {
+			c.done[id] = true
+		}
+	}
+	return len(complete) < len(raw), nil
+}
+
+// Done reports whether id was stored by a previous run.
+func (c *checkpoint) Done(id string) bool {
+	return c.done[id]
+}
+
+// Len returns the number of IDs loaded from a previous run.
+func (c *checkpoint) Len() int {
+	return len(c.done)
+}
+
+// Add journals a stored document. It is buffered until Flush.
+func (c *checkpoint) Add(id string) error {
+	line, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.w, "%s\n", line)
+	return err
+}
+
+func (c *checkpoint) Flush() error {
+	return c.w.Flush()
+}
+
+func (c *checkpoint) Close() error {
+	if err := c.w.Flush(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// bulkFailure is one entry of the dead-letter file: a document that failed,
+// or an input line that could not be parsed.
+type bulkFailure struct {
+	ID    string `json:"id,omitempty"`
+	Line  int    `json:"line,omitempty"`
+	Error string `json:"error"`
+}
+
+// writeDeadLetter writes the failures as JSONL to path. Without failures a
+// dead-letter file left by a previous run is removed, so the file always
+// describes the last run.
+func writeDeadLetter(path string, failures []bulkFailure) error {
+	if len(failures) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, failure := range failures {
+		if err := enc.Encode(failure); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.checkpoint")
+
+	cp, err := openCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("openCheckpoint() error = %v", err)
+	}
+	for _, id := range []string{"a", "b\nc", ""} {
+		if err := cp.Add(id); err != nil {
+			t.Fatalf("Add(%q) error = %v", id, err)
+		}
+	}
+	if err := cp.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	t.Run("Resume", func(t *testing.T) {
+		cp, err := openCheckpoint(path, true)
+		if err != nil {
+			t.Fatalf("openCheckpoint() error = %v", err)
+		}
+		defer cp.Close()
+		if cp.Len() != 3 {
+			t.Errorf("Len() = %d, want 3", cp.Len())
+		}
+		for _, id := range []string{"a", "b\nc", ""} {
+			if !cp.Done(id) {
+				t.Errorf("expected %q to be done", id)
+			}
+		}
+		for _, id := range []string{"b", "c", "d"} {
+			if cp.Done(id) {
+				t.Errorf("expected %q not to be done", id)
+			}
+		}
+	})
+
+	t.Run("CutOff", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(`"trunc`)
+		f.Close()
+
+		cp, err := openCheckpoint(path, true)
+		if err != nil {
+			t.Fatalf("openCheckpoint() error = %v", err)
+		}
+		if cp.Done("trunc") || cp.Len() != 3 {
+			t.Errorf("expected the cut-off line to be ignored, got %d IDs", cp.Len())
+		}
+		if err := cp.Add("d"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cp.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		cp, err = openCheckpoint(path, true)
+		if err != nil {
+			t.Fatalf("openCheckpoint() error = %v", err)
+		}
+		defer cp.Close()
+		if !cp.Done("d") || cp.Len() != 4 {
+			t.Errorf("expected the ID after the cut-off line to be loaded, got %d IDs", cp.Len())
+		}
+	})
+
+	t.Run("Truncate", func(t *testing.T) {
+		cp, err := openCheckpoint(path, false)
+		if err != nil {
+			t.Fatalf("openCheckpoint() error = %v", err)
+		}
+		cp.Close()
+
+		cp, err = openCheckpoint(path, true)
+		if err != nil {
+			t.Fatalf("openCheckpoint() error = %v", err)
+		}
+		defer cp.Close()
+		if cp.Len() != 0 {
+			t.Errorf("expected a fresh run to truncate the journal, got %d IDs", cp.Len())
+		}
+	})
+}
+
+func TestWriteDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.failed.jsonl")
+
+	failures := []bulkFailure{{ID: "a", Error: "boom"}, {Line: 3, Error: "invalid JSON"}}
+	if err := writeDeadLetter(path, failures); err != nil {
+		t.Fatalf("writeDeadLetter() error = %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"id\":\"a\",\"error\":\"boom\"}\n{\"line\":3,\"error\":\"invalid JSON\"}\n"
+	if string(raw) != want {
+		t.Errorf("dead letter = %q, want %q", raw, want)
+	}
+
+	if err := writeDeadLetter(path, nil); err != nil {
+		t.Fatalf("writeDeadLetter() error = %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the dead-letter file to be removed, got %v", err)
+	}
+	if err := writeDeadLetter(path, nil); err != nil {
+		t.Errorf("expected no error without a dead-letter file, got %v", err)
+	}
+}
@@ -4,62 +4,111 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/bondzai/grextor/internal/embed"
+	"github.com/bondzai/grextor/internal/config"
 	"github.com/bondzai/grextor/internal/engine"
-	"github.com/bondzai/grextor/internal/graph"
-	"github.com/bondzai/grextor/internal/vector"
 	"github.com/google/uuid"
 )
 
 func main() {
+	cfgFlags := config.RegisterFlags(flag.CommandLine)
 	var (
-		qdrantAddr = flag.String("qdrant-addr", "localhost:6334", "Qdrant gRPC address")
-		neo4jURI   = flag.String("neo4j-uri", "bolt://localhost:7687", "Neo4j URI")
-		neo4jUser  = flag.String("neo4j-user", "neo4j", "Neo4j username")
-		neo4jPass  = flag.String("neo4j-pass", "grextor123", "Neo4j password")
-		collection = flag.String("collection", "grextor_docs", "Qdrant collection name")
-		content    = flag.String("content", "", "Content to ingest")
-		docID      = flag.String("id", "", "Document ID (optional, generated if empty)")
+		content = flag.String("content", "", "Content to ingest")
+		docID   = flag.String("id", "", "Document ID such as a path or ticket number (optional, generated if empty)")
+		fields  = fieldFlags{}
+		images  imageFlags
+
+		input     = flag.String("input", "", "JSONL file of documents to ingest in bulk (- for stdin)")
+		batchSize = flag.Int("batch-size", 100, "Documents written per batch with --input")
+		workers   = flag.Int("workers", 4, "Documents embedded concurrently with --input")
+		resume    = flag.Bool("resume", false, "Skip the documents recorded in the checkpoint of a previous --input run")
+		cpFile    = flag.String("checkpoint", "", "Journal of stored document IDs (default: <input>.checkpoint)")
+		deadFile  = flag.String("dead-letter", "", "File listing failed documents and invalid lines (default: <input>.failed.jsonl)")
+		dedupe    = flag.Bool("dedupe", false, "Link documents repeating a stored document under another ID with DUPLICATE_OF instead of storing their vector")
+		versioned = flag.Bool("versioned", false, "Keep earlier revisions of changed documents for --as-of searches")
+		nearDup   = flag.Float64("near-duplicate", 0, "With --dedupe, also treat documents scoring at least this against a stored one as duplicates (0 = exact only)")
 	)
+	flag.Var(fields, "field", "Named field to embed into its own vector, as name=text (repeatable)")
+	flag.Var(&images, "image", "Image file referenced by the document (repeatable)")
 	flag.Parse()
 
-	if *content == "" {
-		log.Fatal("Please provide content to ingest using --content or pass a file (not yet impl)")
+	if *content == "" && *input == "" {
+		log.Fatal("Please provide content to ingest using --content or a JSONL file using --input")
+	}
+	if *batchSize <= 0 || *workers <= 0 {
+		log.Fatal("--batch-size and --workers must be positive")
+	}
+	if *input != "" {
+		base := *input
+		if base == "-" {
+			base = "stdin"
+		}
+		if *cpFile == "" {
+			*cpFile = base + ".checkpoint"
+		}
+		if *deadFile == "" {
+			*deadFile = base + ".failed.jsonl"
+		}
 	}
 	if *docID == "" {
 		*docID = uuid.New().String()
 	}
 
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	log.Printf("Using %s", cfg)
+
 	ctx := context.Background()
 
 	// 1. Setup Embedder
-	var embedder embed.Embedder
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey != "" {
-		log.Println("Using OpenAI Embedder")
-		embedder = embed.NewOpenAIEmbedder(apiKey, "")
-	} else {
-		log.Println("Using NoOp Embedder (Dummy)")
-		embedder = embed.NewNoOpEmbedder(1536)
+	embedder, err := cfg.NewEmbedder()
+	if err != nil {
+		log.Fatalf("Failed to set up embedder: %v", err)
+	}
+	if closer, ok := embedder.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	dims, err := cfg.VectorSize(ctx, embedder)
+	if err != nil {
+		log.Fatalf("Failed to determine vector size: %v", err)
 	}
 
 	// 2. Setup Vector Store (Qdrant)
-	vStore, err := vector.NewQdrantStore(*qdrantAddr, *collection, 1536)
+	if *dedupe {
+		// Exact duplicates are looked up by content hash.
+		cfg.AddPayloadIndex(engine.ContentHashKey, "keyword")
+	}
+	vStore, err := cfg.NewVectorStore(dims)
 	if err != nil {
 		log.Fatalf("Failed to connect to Qdrant: %v", err)
 	}
 	defer vStore.Close()
 
+	// Verify Qdrant
+	version, err := vStore.HealthCheck(ctx)
+	if err != nil {
+		log.Fatalf("Failed to reach Qdrant: %v", err)
+	}
+	log.Printf("Connected to Qdrant %s", version)
+
 	if err := vStore.EnsureCollection(ctx); err != nil {
 		log.Fatalf("Failed to ensure collection: %v", err)
 	}
 
 	// 3. Setup Graph Store (Neo4j)
-	gStore, err := graph.NewNeo4jStore(*neo4jURI, *neo4jUser, *neo4jPass)
+	gStore, err := cfg.NewGraphStore()
 	if err != nil {
 		log.Fatalf("Failed to connect to Neo4j: %v", err)
 	}
@@ -69,15 +118,91 @@ func main() {
 	if err := gStore.VerifyConnectivity(ctx); err != nil {
 		log.Fatalf("Failed to verify Neo4j connectivity: %v. Make sure Docker is running.", err)
 	}
+	if err := gStore.EnsureSchema(ctx); err != nil {
+		log.Fatalf("Failed to ensure graph schema: %v", err)
+	}
 
-	// 4. Initialize Engine
-	eng := engine.NewEngine(embedder, vStore, gStore)
+	// 4. Setup Image Store (optional)
+	var engOpts []engine.Option
+	if imageEmbedder := cfg.NewImageEmbedder(); imageEmbedder != nil {
+		imageDims, err := imageEmbedder.Dimensions(ctx)
+		if err != nil {
+			log.Fatalf("Failed to determine image vector size: %v", err)
+		}
+		iStore, err := cfg.NewImageStore(uint64(imageDims))
+		if err != nil {
+			log.Fatalf("Failed to connect to Qdrant: %v", err)
+		}
+		defer iStore.Close()
+		if err := iStore.EnsureCollection(ctx); err != nil {
+			log.Fatalf("Failed to ensure image collection: %v", err)
+		}
+		engOpts = append(engOpts, engine.WithImages(imageEmbedder, iStore))
+	}
 
-	// 5. Ingest
+	if *dedupe {
+		engOpts = append(engOpts, engine.WithDeduplication(float32(*nearDup)))
+	}
+	if *versioned {
+		engOpts = append(engOpts, engine.WithVersioning())
+	}
+	if cfg.Tenant.ID != "" {
+		engOpts = append(engOpts, engine.WithTenant(cfg.Tenant.ID))
+	}
+
+	// 5. Initialize Engine
+	eng := engine.NewEngine(embedder, vStore, gStore, engOpts...)
+	if err := eng.Validate(ctx); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	// 6. Ingest
 	start := time.Now()
-	err = eng.IngestDocument(ctx, *docID, *content, map[string]interface{}{
-		"source": "cli",
-		"time":   time.Now().Format(time.RFC3339),
+	if *input != "" {
+		r, err := openInput(*input)
+		if err != nil {
+			log.Fatalf("Failed to open input: %v", err)
+		}
+		defer r.Close()
+
+		cp, err := openCheckpoint(*cpFile, *resume)
+		if err != nil {
+			log.Fatalf("Failed to open checkpoint: %v", err)
+		}
+		if *resume {
+			log.Printf("Resuming: skipping %d documents recorded in %s", cp.Len(), *cpFile)
+		}
+
+		res, err := ingestFile(ctx, eng, r, *input, engine.IngestOptions{EmbedWorkers: *workers, BatchSize: *batchSize}, cp)
+		if cerr := cp.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("writing checkpoint: %w", cerr)
+		}
+		if derr := writeDeadLetter(*deadFile, res.Failures); derr != nil {
+			log.Printf("Failed to write dead-letter file: %v", derr)
+		} else if len(res.Failures) > 0 {
+			log.Printf("Wrote %d failures to %s", len(res.Failures), *deadFile)
+		}
+		if err != nil {
+			log.Fatalf("Ingestion failed after %d documents: %v (re-run with --resume)", res.Stored, err)
+		}
+		fmt.Printf("Ingested %d documents and %d links, %d skipped, %d failed, %d invalid lines (took %v)\n",
+			res.Stored, res.Links, res.Skipped, res.Failed, res.Invalid, time.Since(start))
+		if len(res.Failures) > 0 {
+			fmt.Printf("Re-run with --resume to retry the failed documents listed in %s\n", *deadFile)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = eng.Ingest(ctx, engine.Document{
+		ID:      *docID,
+		Content: *content,
+		Fields:  fields,
+		Images:  images,
+		Metadata: map[string]interface{}{
+			"source": "cli",
+			"time":   time.Now().Format(time.RFC3339),
+		},
 	})
 	if err != nil {
 		log.Fatalf("Ingestion failed: %v", err)
@@ -85,3 +210,35 @@ func main() {
 
 	fmt.Printf("Ingestion successful! ID: %s (took %v)\n", *docID, time.Since(start))
 }
+
+// fieldFlags collects repeated --field name=text flags.
+type fieldFlags map[string]string
+
+func (f fieldFlags) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f fieldFlags) Set(v string) error {
+	name, text, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=text, got %q", v)
+	}
+	f[name] = text
+	return nil
+}
+
+// imageFlags reads repeated --image paths.
+type imageFlags []engine.Image
+
+func (f *imageFlags) String() string {
+	return fmt.Sprint(len(*f), " images")
+}
+
+func (f *imageFlags) Set(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, engine.Image{Data: data, Source: path, Caption: filepath.Base(path)})
+	return nil
+}
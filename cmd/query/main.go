@@ -4,69 +4,183 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"time"
 
-	"github.com/bondzai/grextor/internal/embed"
+	"github.com/bondzai/grextor/internal/config"
 	"github.com/bondzai/grextor/internal/engine"
-	"github.com/bondzai/grextor/internal/graph"
-	"github.com/bondzai/grextor/internal/vector"
 )
 
 func main() {
+	cfgFlags := config.RegisterFlags(flag.CommandLine)
 	var (
-		qdrantAddr = flag.String("qdrant-addr", "localhost:6334", "Qdrant gRPC address")
-		neo4jURI   = flag.String("neo4j-uri", "bolt://localhost:7687", "Neo4j URI")
-		neo4jUser  = flag.String("neo4j-user", "neo4j", "Neo4j username")
-		neo4jPass  = flag.String("neo4j-pass", "grextor123", "Neo4j password")
-		collection = flag.String("collection", "grextor_docs", "Qdrant collection name")
 		query      = flag.String("q", "", "Query text")
 		limit      = flag.Int("limit", 5, "Number of results")
+		mmrLambda  = flag.Float64("mmr-lambda", -1, "MMR relevance/diversity trade-off in [0,1] (disabled if negative)")
+		maxPerDoc  = flag.Int("max-per-parent", 0, "Maximum results per parent document (0 = unlimited)")
+		cursor     = flag.String("cursor", "", "Cursor from a previous page of results")
+		minScore   = flag.Float64("min-score", 0, "Minimum similarity score (no threshold unless set)")
+		format     = flag.String("format", formatText, "Output format: text, json, jsonl, csv, table or markdown")
+		fields     = flag.String("fields", "", "Comma-separated fields to output, e.g. id,score,metadata.path")
+		maxContent = flag.Int("max-content", 0, "Truncate content to this many characters (0 = no limit)")
+		vectors    = flag.String("vectors", "", "Comma-separated named vectors to search; several are fused")
+		ef         = flag.Int("ef", 0, "HNSW candidate list size for this query (0 = collection default)")
+		exact      = flag.Bool("exact", false, "Score every point instead of using the index")
+		images     = flag.Bool("images", false, "Search images instead of documents (needs image.url)")
+		asOf       = flag.String("as-of", "", "Search the documents as they were at this time, as RFC 3339 or YYYY-MM-DD (needs versioned ingestion)")
 	)
 	flag.Parse()
 
 	if *query == "" {
 		log.Fatal("Please provide a query using -q")
 	}
+	asOfTime, err := parseAsOf(*asOf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := checkFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := cfgFlags.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	log.Printf("Using %s", cfg)
 
 	ctx := context.Background()
 
 	// 1. Setup Embedder
-	var embedder embed.Embedder
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey != "" {
-		// log.Println("Using OpenAI Embedder")
-		embedder = embed.NewOpenAIEmbedder(apiKey, "")
-	} else {
-		// log.Println("Using NoOp Embedder (Dummy)")
-		embedder = embed.NewNoOpEmbedder(1536)
+	embedder, err := cfg.NewEmbedder()
+	if err != nil {
+		log.Fatalf("Failed to set up embedder: %v", err)
+	}
+	if closer, ok := embedder.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	dims, err := cfg.VectorSize(ctx, embedder)
+	if err != nil {
+		log.Fatalf("Failed to determine vector size: %v", err)
 	}
 
 	// 2. Setup Vector Store (Qdrant)
-	vStore, err := vector.NewQdrantStore(*qdrantAddr, *collection, 1536)
+	vStore, err := cfg.NewVectorStore(dims)
 	if err != nil {
 		log.Fatalf("Failed to connect to Qdrant: %v", err)
 	}
 	defer vStore.Close()
 
+	// Verify Qdrant
+	version, err := vStore.HealthCheck(ctx)
+	if err != nil {
+		log.Fatalf("Failed to reach Qdrant: %v", err)
+	}
+	log.Printf("Connected to Qdrant %s", version)
+
 	// 3. Setup Graph Store (Neo4j)
-	gStore, err := graph.NewNeo4jStore(*neo4jURI, *neo4jUser, *neo4jPass)
+	gStore, err := cfg.NewGraphStore()
 	if err != nil {
 		log.Fatalf("Failed to connect to Neo4j: %v", err)
 	}
 	defer gStore.Close(ctx)
 
-	// 4. Initialize Engine
-	eng := engine.NewEngine(embedder, vStore, gStore)
+	// 4. Setup Image Store (optional)
+	var engOpts []engine.Option
+	if imageEmbedder := cfg.NewImageEmbedder(); imageEmbedder != nil {
+		imageDims, err := imageEmbedder.Dimensions(ctx)
+		if err != nil {
+			log.Fatalf("Failed to determine image vector size: %v", err)
+		}
+		iStore, err := cfg.NewImageStore(uint64(imageDims))
+		if err != nil {
+			log.Fatalf("Failed to connect to Qdrant: %v", err)
+		}
+		defer iStore.Close()
+		engOpts = append(engOpts, engine.WithImages(imageEmbedder, iStore))
+	}
+	if cfg.Tenant.ID != "" {
+		engOpts = append(engOpts, engine.WithTenant(cfg.Tenant.ID))
+	}
+
+	// 5. Initialize Engine
+	eng := engine.NewEngine(embedder, vStore, gStore, engOpts...)
+	if err := eng.Validate(ctx); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
 
-	// 5. Search
-	results, err := eng.Search(ctx, *query, *limit)
+	// 6. Search
+	opts := engine.SearchOptions{
+		Limit:        *limit,
+		MaxPerParent: *maxPerDoc,
+		Cursor:       *cursor,
+		Vectors:      parseFields(*vectors),
+		HNSWEf:       *ef,
+		Exact:        *exact,
+		AsOf:         asOfTime,
+	}
+	if *mmrLambda >= 0 {
+		opts.MMRLambda = mmrLambda
+	}
+	// An explicit --min-score 0 still drops negative scores.
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "min-score" {
+			threshold := float32(*minScore)
+			opts.ScoreThreshold = &threshold
+		}
+	})
+	page := &engine.SearchPage{}
+	if *images {
+		page.Results, err = eng.SearchImages(ctx, *query, *limit)
+	} else {
+		page, err = eng.SearchPage(ctx, *query, opts)
+	}
 	if err != nil {
 		log.Fatalf("Search failed: %v", err)
 	}
+	results := page.Results
+
+	if *format != formatText {
+		err := writeResults(os.Stdout, results, outputOptions{
+			Format:     *format,
+			Fields:     parseFields(*fields),
+			MaxContent: *maxContent,
+		})
+		if err != nil {
+			log.Fatalf("Writing results failed: %v", err)
+		}
+		if page.NextCursor != "" {
+			fmt.Fprintf(os.Stderr, "next_cursor: %s\n", page.NextCursor)
+		}
+		return
+	}
 
 	fmt.Printf("Found %d results for '%s':\n", len(results), *query)
 	for i, res := range results {
-		fmt.Printf("%d. [Score: %.4f] %s\n   Content: %s\n", i+1, res.Score, res.ID, res.Content)
+		fmt.Printf("%d. [Score: %.4f] %s\n   Content: %s\n", i+1, res.Score, res.ID, truncate(res.Content, *maxContent))
+	}
+	if page.NextCursor != "" {
+		fmt.Printf("Next page: --cursor %s\n", page.NextCursor)
+	}
+}
+
+// parseAsOf parses --as-of. A bare date means the end of that day in UTC,
+// so documents changed during the day are seen as they were at its end.
+func parseAsOf(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--as-of must be RFC 3339 or YYYY-MM-DD, got %q", s)
 	}
+	return day.Add(24*time.Hour - time.Second), nil
 }
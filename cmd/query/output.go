@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bondzai/grextor/internal/engine"
+)
+
+// Supported values for --format.
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatJSONL    = "jsonl"
+	formatCSV      = "csv"
+	formatTable    = "table"
+	formatMarkdown = "markdown"
+)
+
+// defaultColumns are used by the tabular formats when --fields is empty.
+var defaultColumns = []string{"id", "score", "content"}
+
+// outputOptions controls how search results are rendered.
+type outputOptions struct {
+	Format     string
+	Fields     []string
+	MaxContent int
+}
+
+// checkFormat rejects unknown --format values before any work is done.
+func checkFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatJSONL, formatCSV, formatTable, formatMarkdown:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (want text, json, jsonl, csv, table or markdown)", format)
+}
+
+// parseFields splits a comma-separated --fields value.
+func parseFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// writeResults renders results to w in the requested format.
+func writeResults(w io.Writer, results []engine.SearchResult, opts outputOptions) error {
+	rows := make([]map[string]interface{}, len(results))
+	for i, res := range results {
+		row, err := toRow(res, opts.MaxContent)
+		if err != nil {
+			return err
+		}
+		rows[i] = row
+	}
+
+	switch opts.Format {
+	case formatJSON, formatJSONL:
+		records := make([]interface{}, len(rows))
+		for i, row := range rows {
+			records[i] = row
+			if len(opts.Fields) > 0 {
+				records[i] = selectFields(row, opts.Fields)
+			}
+		}
+		enc := json.NewEncoder(w)
+		if opts.Format == formatJSONL {
+			for _, rec := range records {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		columns := columnsOrDefault(opts.Fields)
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := cw.Write(cells(row, columns, false)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case formatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		columns := columnsOrDefault(opts.Fields)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(cells(row, columns, true), "\t"))
+		}
+		return tw.Flush()
+	case formatMarkdown:
+		columns := columnsOrDefault(opts.Fields)
+		fmt.Fprintf(w, "| %s |\n", strings.Join(columns, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(columns)))
+		for _, row := range rows {
+			values := cells(row, columns, true)
+			for i, v := range values {
+				values[i] = strings.ReplaceAll(v, "|", `\|`)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(values, " | "))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q", opts.Format)
+	}
+}
+
+// toRow converts a result to a generic map using its JSON tags, so field
+// names match the JSON output.
+func toRow(res engine.SearchResult, maxContent int) (map[string]interface{}, error) {
+	res.Content = truncate(res.Content, maxContent)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("encoding result %s: %w", res.ID, err)
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, fmt.Errorf("encoding result %s: %w", res.ID, err)
+	}
+	if meta, ok := row["metadata"].(map[string]interface{}); ok {
+		if c, ok := meta["content"].(string); ok {
+			meta["content"] = truncate(c, maxContent)
+		}
+	}
+	return row, nil
+}
+
+// lookup resolves a dotted field path such as "metadata.path".
+func lookup(row map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = row
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// selectFields returns a flat record keyed by the requested field paths.
+func selectFields(row map[string]interface{}, fields []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		v, _ := lookup(row, f)
+		out[f] = v
+	}
+	return out
+}
+
+// cells renders the given columns of row. Single-line mode collapses
+// whitespace so values fit in one table row.
+func cells(row map[string]interface{}, columns []string, singleLine bool) []string {
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		if v, ok := lookup(row, c); ok {
+			values[i] = formatCell(v)
+		}
+		if singleLine {
+			values[i] = strings.Join(strings.Fields(values[i]), " ")
+		}
+	}
+	return values
+}
+
+func formatCell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(val)
+		return string(raw)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func columnsOrDefault(fields []string) []string {
+	if len(fields) == 0 {
+		return defaultColumns
+	}
+	return fields
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis.
+// A non-positive max disables truncation.
+func truncate(s string, max int) string {
+	if max <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "…"
+}
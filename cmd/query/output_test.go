@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bondzai/grextor/internal/engine"
+)
+
+func TestWriteResults(t *testing.T) {
+	results := []engine.SearchResult{
+		{
+			ID:       "doc-1",
+			Score:    0.5,
+			Content:  "hello\nworld",
+			Metadata: map[string]interface{}{"path": "a/b.md", "content": "hello\nworld"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		opts outputOptions
+		want string
+	}{
+		{
+			name: "JSONL",
+			opts: outputOptions{Format: formatJSONL, Fields: []string{"id", "metadata.path"}},
+			want: `{"id":"doc-1","metadata.path":"a/b.md"}` + "\n",
+		},
+		{
+			name: "CSV",
+			opts: outputOptions{Format: formatCSV, Fields: []string{"id", "score", "content"}, MaxContent: 5},
+			want: "id,score,content\ndoc-1,0.5,hello…\n",
+		},
+		{
+			name: "Markdown",
+			opts: outputOptions{Format: formatMarkdown},
+			want: "| id | score | content |\n| --- | --- | --- |\n| doc-1 | 0.5 | hello world |\n",
+		},
+		{
+			name: "Table",
+			opts: outputOptions{Format: formatTable, Fields: []string{"id", "metadata.missing"}},
+			want: "ID     METADATA.MISSING\ndoc-1  \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeResults(&buf, results, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, buf.String())
+			}
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeResults(&buf, results, outputOptions{Format: formatJSON}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(buf.String(), `"metadata": {`) {
+			t.Errorf("expected full metadata object, got %s", buf.String())
+		}
+	})
+}
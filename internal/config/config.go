@@ -0,0 +1,555 @@
+// Package config loads the connection settings shared by the grextor CLIs.
+//
+// Settings are resolved in increasing order of precedence: built-in defaults,
+// the config file (base section, then the selected profile), GREXTOR_*
+// environment variables and finally explicitly set command-line flags.
+// Secrets may be read from files via the *_file settings; a file is read in
+// the layer that names it, so a higher layer may still set the secret itself.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/bondzai/grextor/internal/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes every environment variable read by this package.
+const EnvPrefix = "GREXTOR_"
+
+// DefaultFiles are looked up in the working directory when no config file
+// is given explicitly.
+var DefaultFiles = []string{"grextor.yaml", "grextor.yml", "grextor.toml"}
+
+// Config holds the settings needed to connect to the backing services.
+type Config struct {
+	// Profile is the name of the profile that was applied, if any.
+	Profile string `json:"-"`
+
+	Qdrant    QdrantConfig    `json:"qdrant"`
+	Neo4j     Neo4jConfig     `json:"neo4j"`
+	Embedding EmbeddingConfig `json:"embedding"`
+	Cache     CacheConfig     `json:"cache"`
+	Retry     RetryConfig     `json:"retry"`
+	Image     ImageConfig     `json:"image"`
+	Tenant    TenantConfig    `json:"tenant"`
+}
+
+type QdrantConfig struct {
+	Addr       string `json:"addr"`
+	Collection string `json:"collection"`
+	// Vectors is a comma-separated list of named vectors, e.g.
+	// "body,title,code". The first name receives the document content. Empty
+	// keeps a single unnamed vector per point.
+	Vectors string `json:"vectors"`
+
+	// Distance is cosine (default), dot, euclid or manhattan. It cannot be
+	// changed once the collection exists.
+	Distance        string `json:"distance"`
+	HNSWM           int    `json:"hnsw_m"`
+	HNSWEfConstruct int    `json:"hnsw_ef_construct"`
+	// OnDisk keeps the original vectors on disk; combine it with
+	// Quantization ("scalar" or "binary") to keep large collections in RAM.
+	// Unset, neither changes an existing collection; "none" removes
+	// quantization.
+	OnDisk       bool   `json:"on_disk"`
+	Quantization string `json:"quantization"`
+	// PayloadIndexes maps payload fields used in filters to their index
+	// type, e.g. {tenant: keyword, updated_at: datetime}.
+	PayloadIndexes map[string]string `json:"payload_indexes"`
+
+	// TLS encrypts the connection; CAFile adds a private CA to the trusted
+	// roots.
+	TLS        bool   `json:"tls"`
+	CAFile     string `json:"ca_file"`
+	APIKey     string `json:"api_key"`
+	APIKeyFile string `json:"api_key_file"`
+	// Timeout bounds each call and Keepalive is the ping interval of idle
+	// connections, both as Go durations such as "30s".
+	Timeout   string `json:"timeout"`
+	Keepalive string `json:"keepalive"`
+}
+
+type Neo4jConfig struct {
+	URI          string `json:"uri"`
+	User         string `json:"user"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"`
+	// Token and TokenFile select bearer (SSO) authentication instead of the
+	// user and password.
+	Token     string `json:"token"`
+	TokenFile string `json:"token_file"`
+	Realm     string `json:"realm"`
+	// Database selects the target database; empty uses the server default.
+	Database    string `json:"database"`
+	CAFile      string `json:"ca_file"`
+	MaxPoolSize int    `json:"max_pool_size"`
+	// MaxRetryTime and QueryTimeout are Go durations such as "30s".
+	MaxRetryTime string `json:"max_retry_time"`
+	QueryTimeout string `json:"query_timeout"`
+	// BatchSize caps the rows sent in one UNWIND statement; 0 uses the
+	// store default.
+	BatchSize int `json:"batch_size"`
+}
+
+type EmbeddingConfig struct {
+	// Provider selects the embedder: "openai", "hashing" or "noop". When
+	// empty, OpenAI is used if an API key or base URL is configured and the
+	// offline hashing embedder otherwise.
+	Provider   string `json:"provider"`
+	Model      string `json:"model"`
+	APIKey     string `json:"api_key"`
+	APIKeyFile string `json:"api_key_file"`
+	// BaseURL points the OpenAI provider at an OpenAI-compatible gateway
+	// (vLLM, LocalAI) or, with AzureDeployment, an Azure OpenAI resource.
+	BaseURL         string `json:"base_url"`
+	Organization    string `json:"organization"`
+	AzureDeployment string `json:"azure_deployment"`
+	AzureAPIVersion string `json:"azure_api_version"`
+	// MaxTokens is the input token limit; 0 uses the model's known limit
+	// and a negative value disables the check.
+	MaxTokens int `json:"max_tokens"`
+	// Overflow is the strategy for longer inputs: error, truncate_head,
+	// truncate_tail or split_average. Unset with MaxTokens also unset, inputs
+	// are sent unchecked and the API enforces its limit.
+	Overflow string `json:"overflow"`
+	// PreserveNewlines sends newlines as-is instead of replacing them with
+	// spaces, which suits code.
+	PreserveNewlines bool `json:"preserve_newlines"`
+	// OutputDimensions is sent as the "dimensions" parameter of models that
+	// support shortened embeddings.
+	OutputDimensions int `json:"output_dimensions"`
+	// Dimensions is the expected vector size. Zero detects it from the
+	// embedder; a non-zero value must match what the embedder produces.
+	Dimensions int `json:"dimensions"`
+}
+
+// CacheConfig configures the embedding cache. It is disabled when both
+// fields are zero.
+type CacheConfig struct {
+	// Path of the on-disk cache file; empty keeps the cache in memory only.
+	Path string `json:"path"`
+	// MemoryEntries is the size of the in-memory LRU in front of the file.
+	MemoryEntries int `json:"memory_entries"`
+}
+
+// RetryConfig configures retries and rate limits for remote embedders.
+type RetryConfig struct {
+	// MaxAttempts per embedding call; 1 disables retries.
+	MaxAttempts       int `json:"max_attempts"`
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+}
+
+// ImageConfig configures image embeddings. They are disabled when URL is
+// empty.
+type ImageConfig struct {
+	// URL of the CLIP-style model server.
+	URL string `json:"url"`
+	// Collection holds the image vectors, which live in a different vector
+	// space than text.
+	Collection string `json:"collection"`
+	// Dimensions is the image vector size; zero probes the model server.
+	Dimensions int `json:"dimensions"`
+}
+
+// TenantConfig scopes the CLIs to one tenant of a shared deployment. It is
+// disabled when ID is empty.
+type TenantConfig struct {
+	// ID names the tenant: letters, digits, "_" and "-".
+	ID string `json:"id"`
+	// Isolation is "payload" (default), which keeps all tenants in the
+	// configured collections and filters on their tenant_id, or
+	// "collection", which gives every tenant collections of its own named
+	// "<collection>_<id>". The graph is shared either way.
+	Isolation string `json:"isolation"`
+}
+
+// Tenant isolation modes.
+const (
+	IsolationPayload    = "payload"
+	IsolationCollection = "collection"
+)
+
+// Defaults returns the built-in settings, matching docker-compose.yaml
+// except for credentials, which must always be configured.
+func Defaults() *Config {
+	return &Config{
+		Qdrant: QdrantConfig{
+			Addr:       "localhost:6334",
+			Collection: "grextor_docs",
+		},
+		Image: ImageConfig{
+			Collection: "grextor_images",
+		},
+		Neo4j: Neo4jConfig{
+			URI:  "bolt://localhost:7687",
+			User: "neo4j",
+		},
+	}
+}
+
+// setting describes one scalar option and how it can be overridden.
+type setting struct {
+	key   string // dotted config key, also used to derive the env var
+	flag  string
+	usage string
+	ptr   func(c *Config) interface{}
+}
+
+var settings = []setting{
+	{"qdrant.addr", "qdrant-addr", "Qdrant gRPC address", func(c *Config) interface{} { return &c.Qdrant.Addr }},
+	{"qdrant.collection", "collection", "Qdrant collection name", func(c *Config) interface{} { return &c.Qdrant.Collection }},
+	{"qdrant.vectors", "vectors", "Comma-separated named vectors; the first holds the content", func(c *Config) interface{} { return &c.Qdrant.Vectors }},
+	{"qdrant.distance", "distance", "Vector distance: cosine, dot, euclid or manhattan", func(c *Config) interface{} { return &c.Qdrant.Distance }},
+	{"qdrant.hnsw_m", "hnsw-m", "HNSW edges per node (0 = Qdrant default)", func(c *Config) interface{} { return &c.Qdrant.HNSWM }},
+	{"qdrant.hnsw_ef_construct", "hnsw-ef-construct", "HNSW build candidate list size (0 = Qdrant default)", func(c *Config) interface{} { return &c.Qdrant.HNSWEfConstruct }},
+	{"qdrant.on_disk", "on-disk", "Store original vectors on disk", func(c *Config) interface{} { return &c.Qdrant.OnDisk }},
+	{"qdrant.quantization", "quantization", "Vector quantization: scalar, binary or none (empty = unchanged)", func(c *Config) interface{} { return &c.Qdrant.Quantization }},
+	{"qdrant.tls", "qdrant-tls", "Connect to Qdrant over TLS", func(c *Config) interface{} { return &c.Qdrant.TLS }},
+	{"qdrant.ca_file", "qdrant-ca-file", "PEM file with the CA of the Qdrant server", func(c *Config) interface{} { return &c.Qdrant.CAFile }},
+	{"qdrant.api_key", "", "", func(c *Config) interface{} { return &c.Qdrant.APIKey }},
+	{"qdrant.api_key_file", "qdrant-api-key-file", "File containing the Qdrant API key", func(c *Config) interface{} { return &c.Qdrant.APIKeyFile }},
+	{"qdrant.timeout", "qdrant-timeout", "Deadline of each Qdrant call, e.g. 30s", func(c *Config) interface{} { return &c.Qdrant.Timeout }},
+	{"qdrant.keepalive", "", "", func(c *Config) interface{} { return &c.Qdrant.Keepalive }},
+	{"neo4j.uri", "neo4j-uri", "Neo4j URI", func(c *Config) interface{} { return &c.Neo4j.URI }},
+	{"neo4j.user", "neo4j-user", "Neo4j username", func(c *Config) interface{} { return &c.Neo4j.User }},
+	{"neo4j.password", "neo4j-pass", "Neo4j password", func(c *Config) interface{} { return &c.Neo4j.Password }},
+	{"neo4j.password_file", "neo4j-pass-file", "File containing the Neo4j password", func(c *Config) interface{} { return &c.Neo4j.PasswordFile }},
+	{"neo4j.token", "", "", func(c *Config) interface{} { return &c.Neo4j.Token }},
+	{"neo4j.token_file", "neo4j-token-file", "File containing a Neo4j bearer token (replaces user and password)", func(c *Config) interface{} { return &c.Neo4j.TokenFile }},
+	{"neo4j.realm", "", "", func(c *Config) interface{} { return &c.Neo4j.Realm }},
+	{"neo4j.database", "neo4j-database", "Neo4j database name (empty = server default)", func(c *Config) interface{} { return &c.Neo4j.Database }},
+	{"neo4j.ca_file", "neo4j-ca-file", "PEM file with the CA of the Neo4j server", func(c *Config) interface{} { return &c.Neo4j.CAFile }},
+	{"neo4j.max_pool_size", "", "", func(c *Config) interface{} { return &c.Neo4j.MaxPoolSize }},
+	{"neo4j.batch_size", "", "", func(c *Config) interface{} { return &c.Neo4j.BatchSize }},
+	{"neo4j.max_retry_time", "", "", func(c *Config) interface{} { return &c.Neo4j.MaxRetryTime }},
+	{"neo4j.query_timeout", "neo4j-timeout", "Server-side timeout of each Neo4j transaction, e.g. 30s", func(c *Config) interface{} { return &c.Neo4j.QueryTimeout }},
+	{"embedding.provider", "embedder", "Embedding provider (openai, hashing or noop)", func(c *Config) interface{} { return &c.Embedding.Provider }},
+	{"embedding.model", "embedding-model", "Embedding model name", func(c *Config) interface{} { return &c.Embedding.Model }},
+	{"embedding.api_key", "", "", func(c *Config) interface{} { return &c.Embedding.APIKey }},
+	{"embedding.api_key_file", "api-key-file", "File containing the embedding API key", func(c *Config) interface{} { return &c.Embedding.APIKeyFile }},
+	{"embedding.base_url", "embedding-base-url", "OpenAI-compatible API base URL", func(c *Config) interface{} { return &c.Embedding.BaseURL }},
+	{"embedding.organization", "", "", func(c *Config) interface{} { return &c.Embedding.Organization }},
+	{"embedding.azure_deployment", "", "", func(c *Config) interface{} { return &c.Embedding.AzureDeployment }},
+	{"embedding.azure_api_version", "", "", func(c *Config) interface{} { return &c.Embedding.AzureAPIVersion }},
+	{"embedding.output_dimensions", "", "", func(c *Config) interface{} { return &c.Embedding.OutputDimensions }},
+	{"embedding.max_tokens", "max-tokens", "Embedding input token limit (0 = model default, <0 = unchecked)", func(c *Config) interface{} { return &c.Embedding.MaxTokens }},
+	{"embedding.overflow", "overflow", "Long input strategy: error, truncate_head, truncate_tail or split_average", func(c *Config) interface{} { return &c.Embedding.Overflow }},
+	{"embedding.preserve_newlines", "preserve-newlines", "Send newlines to the embedder unchanged", func(c *Config) interface{} { return &c.Embedding.PreserveNewlines }},
+	{"embedding.dimensions", "dims", "Expected embedding vector size (0 = detect)", func(c *Config) interface{} { return &c.Embedding.Dimensions }},
+	{"retry.max_attempts", "embed-max-attempts", "Attempts per embedding call (0 = default)", func(c *Config) interface{} { return &c.Retry.MaxAttempts }},
+	{"retry.requests_per_minute", "embed-rpm", "Embedding requests per minute (0 = unlimited)", func(c *Config) interface{} { return &c.Retry.RequestsPerMinute }},
+	{"retry.tokens_per_minute", "embed-tpm", "Embedding tokens per minute (0 = unlimited)", func(c *Config) interface{} { return &c.Retry.TokensPerMinute }},
+	{"cache.path", "embed-cache", "Embedding cache file (empty = no disk cache)", func(c *Config) interface{} { return &c.Cache.Path }},
+	{"image.url", "image-url", "CLIP-style image model server URL (empty = no images)", func(c *Config) interface{} { return &c.Image.URL }},
+	{"image.collection", "image-collection", "Qdrant collection for image vectors", func(c *Config) interface{} { return &c.Image.Collection }},
+	{"image.dimensions", "image-dims", "Image vector size (0 = detect)", func(c *Config) interface{} { return &c.Image.Dimensions }},
+	{"cache.memory_entries", "embed-cache-entries", "Embeddings kept in the in-memory cache", func(c *Config) interface{} { return &c.Cache.MemoryEntries }},
+	{"tenant.id", "tenant", "Tenant whose documents are ingested and searched (empty = no tenancy)", func(c *Config) interface{} { return &c.Tenant.ID }},
+	{"tenant.isolation", "tenant-isolation", "Tenant isolation: payload or collection", func(c *Config) interface{} { return &c.Tenant.Isolation }},
+}
+
+// envName returns the environment variable for a dotted key,
+// e.g. "neo4j.password_file" becomes GREXTOR_NEO4J_PASSWORD_FILE.
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func (s setting) set(c *Config, value string) error {
+	switch p := s.ptr(c).(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", s.key, value)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", s.key, value)
+		}
+		*p = b
+	}
+	return nil
+}
+
+func (s setting) get(c *Config) string {
+	switch p := s.ptr(c).(type) {
+	case *string:
+		return *p
+	case *int:
+		return strconv.Itoa(*p)
+	case *bool:
+		return strconv.FormatBool(*p)
+	}
+	return ""
+}
+
+// Load resolves the configuration from the given file (or a default file in
+// the working directory when path is empty), profile, environment and
+// overrides keyed by dotted config key.
+func Load(path, profile string, overrides map[string]string) (*Config, error) {
+	cfg := Defaults()
+
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		for _, name := range DefaultFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+	}
+	if profile == "" {
+		profile = os.Getenv(EnvPrefix + "PROFILE")
+	}
+
+	if path != "" {
+		if err := cfg.loadFile(path, profile); err != nil {
+			return nil, err
+		}
+		if err := cfg.resolveSecrets(func(string) bool { return true }); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q selected but no config file found", profile)
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(envName(s.key)); ok {
+			if err := s.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", envName(s.key), err)
+			}
+		}
+	}
+	if err := cfg.resolveSecrets(func(key string) bool {
+		_, ok := os.LookupEnv(envName(key))
+		return ok
+	}); err != nil {
+		return nil, err
+	}
+	// OPENAI_API_KEY predates this package and is still honoured.
+	if cfg.Embedding.APIKey == "" && cfg.Embedding.APIKeyFile == "" {
+		cfg.Embedding.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	for _, s := range settings {
+		if v, ok := overrides[s.key]; ok {
+			if err := s.set(cfg, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := cfg.resolveSecrets(func(key string) bool {
+		_, ok := overrides[key]
+		return ok
+	}); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile merges the base section of the file and the selected profile.
+func (c *Config) loadFile(path, profile string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	doc := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(raw, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &doc)
+	default:
+		return fmt.Errorf("unsupported config format %q (want .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	profiles, _ := doc["profiles"].(map[string]interface{})
+	delete(doc, "profiles")
+	if profile == "" {
+		profile, _ = doc["default_profile"].(string)
+	}
+	delete(doc, "default_profile")
+
+	if profile == "" && len(profiles) > 0 {
+		return fmt.Errorf("config %s defines profiles %s but none was selected; use --profile or %sPROFILE",
+			path, strings.Join(sortedKeys(profiles), ", "), EnvPrefix)
+	}
+	if profile != "" {
+		overlay, ok := profiles[profile].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("config %s has no profile %q (available: %s)",
+				path, profile, strings.Join(sortedKeys(profiles), ", "))
+		}
+		merge(doc, overlay)
+		c.Profile = profile
+	}
+
+	// Round-trip through JSON so YAML and TOML share the struct tags above.
+	merged, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	dec := json.NewDecoder(strings.NewReader(string(merged)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	return nil
+}
+
+// resolveSecrets replaces secrets with the contents of the *_file settings
+// for which inLayer reports that the layer just applied set them.
+func (c *Config) resolveSecrets(inLayer func(key string) bool) error {
+	for _, sec := range []struct {
+		key    string
+		file   string
+		target *string
+	}{
+		{"qdrant.api_key_file", c.Qdrant.APIKeyFile, &c.Qdrant.APIKey},
+		{"neo4j.password_file", c.Neo4j.PasswordFile, &c.Neo4j.Password},
+		{"neo4j.token_file", c.Neo4j.TokenFile, &c.Neo4j.Token},
+		{"embedding.api_key_file", c.Embedding.APIKeyFile, &c.Embedding.APIKey},
+	} {
+		if sec.file == "" || !inLayer(sec.key) {
+			continue
+		}
+		raw, err := os.ReadFile(sec.file)
+		if err != nil {
+			return fmt.Errorf("reading secret: %w", err)
+		}
+		*sec.target = strings.TrimSpace(string(raw))
+	}
+	return nil
+}
+
+// Validate reports settings that are required but missing.
+func (c *Config) Validate() error {
+	var missing []string
+	if c.Qdrant.Addr == "" {
+		missing = append(missing, "qdrant.addr")
+	}
+	if c.Qdrant.Collection == "" {
+		missing = append(missing, "qdrant.collection")
+	}
+	if c.Neo4j.URI == "" {
+		missing = append(missing, "neo4j.uri")
+	}
+	if c.Neo4j.Password == "" && c.Neo4j.Token == "" {
+		missing = append(missing, "neo4j.password")
+	}
+	if c.Image.URL != "" && c.Image.Collection == "" {
+		missing = append(missing, "image.collection")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required settings: %s", strings.Join(missing, ", "))
+	}
+	if c.Embedding.Dimensions < 0 {
+		return errors.New("embedding.dimensions must not be negative")
+	}
+	if c.Qdrant.HNSWM < 0 || c.Qdrant.HNSWEfConstruct < 0 {
+		return errors.New("qdrant.hnsw_m and qdrant.hnsw_ef_construct must not be negative")
+	}
+	for _, d := range []struct{ key, value string }{
+		{"qdrant.timeout", c.Qdrant.Timeout},
+		{"qdrant.keepalive", c.Qdrant.Keepalive},
+		{"neo4j.max_retry_time", c.Neo4j.MaxRetryTime},
+		{"neo4j.query_timeout", c.Neo4j.QueryTimeout},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil || v < 0 {
+			return fmt.Errorf("%s must be a duration such as 30s, got %q", d.key, d.value)
+		}
+	}
+	if c.Neo4j.MaxPoolSize < 0 {
+		return errors.New("neo4j.max_pool_size must not be negative")
+	}
+	if c.Neo4j.BatchSize < 0 {
+		return errors.New("neo4j.batch_size must not be negative")
+	}
+	if c.Qdrant.CAFile != "" && !c.Qdrant.TLS {
+		return errors.New("qdrant.ca_file requires qdrant.tls")
+	}
+	if err := c.CollectionSpec().Validate(); err != nil {
+		return fmt.Errorf("qdrant: %w", err)
+	}
+	if c.Image.Dimensions < 0 {
+		return errors.New("image.dimensions must not be negative")
+	}
+	switch c.Tenant.Isolation {
+	case "", IsolationPayload:
+	case IsolationCollection:
+		if c.Tenant.ID == "" {
+			return errors.New("tenant.isolation collection requires tenant.id")
+		}
+	default:
+		return fmt.Errorf("tenant.isolation must be payload or collection, got %q", c.Tenant.Isolation)
+	}
+	if c.Tenant.ID != "" {
+		if err := engine.ValidateTenant(c.Tenant.ID); err != nil {
+			return fmt.Errorf("tenant.id: %w", err)
+		}
+	}
+	return nil
+}
+
+// String summarises the target environment without secrets, so the CLIs
+// can log which services they are about to talk to.
+func (c *Config) String() string {
+	profile := c.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+	neo4j := c.Neo4j.URI
+	if c.Neo4j.Database != "" {
+		neo4j += "/" + c.Neo4j.Database
+	}
+	s := fmt.Sprintf("profile=%s qdrant=%s/%s neo4j=%s", profile, c.Qdrant.Addr, c.CollectionName(), neo4j)
+	if c.Tenant.ID != "" {
+		s += " tenant=" + c.Tenant.ID
+	}
+	return s
+}
+
+// merge recursively copies src into dst.
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				merge(dm, sm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
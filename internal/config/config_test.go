@@ -0,0 +1,259 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	secret := writeFile(t, dir, "pass", "s3cret\n")
+	yamlPath := writeFile(t, dir, "grextor.yaml", `
+qdrant:
+  collection: base_docs
+profiles:
+  dev:
+    neo4j:
+      password: devpass
+  prod:
+    qdrant:
+      addr: qdrant.prod:6334
+    neo4j:
+      password_file: `+secret+`
+`)
+
+	t.Run("Profile", func(t *testing.T) {
+		cfg, err := Load(yamlPath, "prod", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Qdrant.Addr != "qdrant.prod:6334" || cfg.Qdrant.Collection != "base_docs" {
+			t.Errorf("unexpected qdrant config: %+v", cfg.Qdrant)
+		}
+		if cfg.Neo4j.Password != "s3cret" {
+			t.Errorf("expected password from file, got %q", cfg.Neo4j.Password)
+		}
+		if cfg.Neo4j.URI != "bolt://localhost:7687" {
+			t.Errorf("expected default neo4j uri, got %q", cfg.Neo4j.URI)
+		}
+	})
+
+	t.Run("SecretPrecedence", func(t *testing.T) {
+		other := writeFile(t, dir, "other", "fromflagfile\n")
+		t.Setenv("GREXTOR_NEO4J_PASSWORD", "fromenv")
+		cfg, err := Load(yamlPath, "prod", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Neo4j.Password != "fromenv" {
+			t.Errorf("expected the environment to win over the profile's password_file, got %q", cfg.Neo4j.Password)
+		}
+
+		cfg, err = Load(yamlPath, "prod", map[string]string{"neo4j.password": "fromflag"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Neo4j.Password != "fromflag" {
+			t.Errorf("expected the flag to win, got %q", cfg.Neo4j.Password)
+		}
+
+		cfg, err = Load(yamlPath, "dev", map[string]string{"neo4j.password_file": other})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Neo4j.Password != "fromflagfile" {
+			t.Errorf("expected a password file from a flag to win over the environment, got %q", cfg.Neo4j.Password)
+		}
+	})
+
+	t.Run("ProfileRequired", func(t *testing.T) {
+		t.Setenv("GREXTOR_PROFILE", "")
+		_, err := Load(yamlPath, "", nil)
+		if err == nil || !strings.Contains(err.Error(), "dev, prod") {
+			t.Errorf("expected missing profile error, got %v", err)
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		t.Setenv("GREXTOR_QDRANT_COLLECTION", "env_docs")
+		t.Setenv("GREXTOR_EMBEDDING_DIMENSIONS", "768")
+		cfg, err := Load(yamlPath, "dev", map[string]string{"qdrant.collection": "flag_docs"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Qdrant.Collection != "flag_docs" {
+			t.Errorf("expected flag to win, got %q", cfg.Qdrant.Collection)
+		}
+		if cfg.Embedding.Dimensions != 768 {
+			t.Errorf("expected dimensions from env, got %d", cfg.Embedding.Dimensions)
+		}
+	})
+
+	t.Run("TOML", func(t *testing.T) {
+		path := writeFile(t, dir, "grextor.toml", `
+[neo4j]
+password = "tomlpass"
+
+[embedding]
+dimensions = 384
+`)
+		cfg, err := Load(path, "", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Neo4j.Password != "tomlpass" || cfg.Embedding.Dimensions != 384 {
+			t.Errorf("unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		path := writeFile(t, dir, "bad.yaml", "qdrant:\n  adr: typo:6334\n")
+		if _, err := Load(path, "", nil); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		ok     bool
+	}{
+		{"password", func(c *Config) { c.Neo4j.Password = "p" }, true},
+		{"bearer token", func(c *Config) { c.Neo4j.Token = "t" }, true},
+		{"no credentials", func(c *Config) {}, false},
+		{"durations", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Neo4j.QueryTimeout = "30s"
+			c.Qdrant.Timeout = "1m"
+		}, true},
+		{"bad duration", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Neo4j.MaxRetryTime = "thirty"
+		}, false},
+		{"ca without tls", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Qdrant.CAFile = "ca.pem"
+		}, false},
+		{"unknown quantization", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Qdrant.Quantization = "pq"
+		}, false},
+		{"tenant", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Tenant.ID = "acme-eu"
+			c.Tenant.Isolation = "collection"
+		}, true},
+		{"bad tenant", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Tenant.ID = "acme:eu"
+		}, false},
+		{"collection isolation without tenant", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Tenant.Isolation = "collection"
+		}, false},
+		{"unknown isolation", func(c *Config) {
+			c.Neo4j.Password = "p"
+			c.Tenant.ID = "acme"
+			c.Tenant.Isolation = "database"
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Defaults()
+			tt.modify(cfg)
+			if err := cfg.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTenantCollections(t *testing.T) {
+	cfg := Defaults()
+	if got := cfg.CollectionSpec().PayloadIndexes; got != nil {
+		t.Errorf("expected no payload indexes without a tenant, got %v", got)
+	}
+
+	cfg.Tenant.ID = "acme"
+	cfg.Qdrant.PayloadIndexes = map[string]string{"updated_at": "datetime"}
+	if cfg.CollectionName() != "grextor_docs" || cfg.ImageCollectionName() != "grextor_images" {
+		t.Errorf("expected shared collections with payload isolation, got %s and %s", cfg.CollectionName(), cfg.ImageCollectionName())
+	}
+	indexes := cfg.CollectionSpec().PayloadIndexes
+	if indexes["tenant_id"] != "keyword" || indexes["updated_at"] != "datetime" {
+		t.Errorf("expected a keyword index on tenant_id next to the configured ones, got %v", indexes)
+	}
+	if _, ok := cfg.Qdrant.PayloadIndexes["tenant_id"]; ok {
+		t.Error("expected the configured indexes to be left unchanged")
+	}
+
+	cfg.Tenant.Isolation = IsolationCollection
+	if cfg.CollectionName() != "grextor_docs_acme" || cfg.ImageCollectionName() != "grextor_images_acme" {
+		t.Errorf("expected tenant collections, got %s and %s", cfg.CollectionName(), cfg.ImageCollectionName())
+	}
+}
+
+func TestAddPayloadIndex(t *testing.T) {
+	cfg := Defaults()
+	cfg.AddPayloadIndex("content_hash", "keyword")
+	if got := cfg.CollectionSpec().PayloadIndexes; got["content_hash"] != "keyword" {
+		t.Errorf("expected a keyword index on content_hash, got %v", got)
+	}
+
+	cfg.Qdrant.PayloadIndexes = map[string]string{"content_hash": "text"}
+	cfg.AddPayloadIndex("content_hash", "keyword")
+	if got := cfg.Qdrant.PayloadIndexes["content_hash"]; got != "text" {
+		t.Errorf("expected the configured index to be kept, got %s", got)
+	}
+}
+
+func TestCacheNamespace(t *testing.T) {
+	cfg := Defaults()
+	base := cfg.cacheNamespace("openai", "text-embedding-ada-002")
+	if base != "openai/text-embedding-ada-002" {
+		t.Errorf("expected the plain model name, got %s", base)
+	}
+
+	seen := map[string]string{base: "default"}
+	for name, modify := range map[string]func(c *Config){
+		"base url": func(c *Config) { c.Embedding.BaseURL = "http://vllm.internal:8000/v1" },
+		"deployment": func(c *Config) {
+			c.Embedding.BaseURL, c.Embedding.AzureDeployment = "https://x.openai.azure.com", "embeddings"
+		},
+		"dimensions": func(c *Config) { c.Embedding.OutputDimensions = 256 },
+	} {
+		cfg := Defaults()
+		modify(cfg)
+		ns := cfg.cacheNamespace("openai", "text-embedding-ada-002")
+		if other, ok := seen[ns]; ok {
+			t.Errorf("%s shares the cache namespace %s with %s", name, ns, other)
+		}
+		seen[ns] = name
+	}
+}
+
+func TestConnOptions(t *testing.T) {
+	cfg := Defaults()
+	if opts := cfg.ConnOptions(); len(opts) != 0 {
+		t.Errorf("expected no connection options by default, got %d", len(opts))
+	}
+	cfg.Qdrant.TLS = true
+	cfg.Qdrant.APIKey = "secret"
+	cfg.Qdrant.Timeout = "30s"
+	if opts := cfg.ConnOptions(); len(opts) != 3 {
+		t.Errorf("expected TLS, API key and timeout options, got %d", len(opts))
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import "flag"
+
+// Flags holds the command-line flags shared by the grextor CLIs.
+type Flags struct {
+	fs      *flag.FlagSet
+	path    *string
+	profile *string
+	values  map[string]*settingValue // by flag name
+}
+
+// settingValue is a flag.Value that keeps the raw string so it can be
+// parsed together with file and environment values.
+type settingValue struct {
+	key    string
+	value  string
+	isBool bool
+}
+
+func (v *settingValue) String() string     { return v.value }
+func (v *settingValue) Set(s string) error { v.value = s; return nil }
+func (v *settingValue) IsBoolFlag() bool   { return v.isBool }
+
+// RegisterFlags defines --config, --profile and one flag per connection
+// setting on fs. Only flags that are explicitly set override other sources.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		fs:      fs,
+		path:    fs.String("config", "", "Config file (default: $GREXTOR_CONFIG or ./grextor.{yaml,yml,toml})"),
+		profile: fs.String("profile", "", "Config profile to use (default: $GREXTOR_PROFILE or default_profile)"),
+		values:  make(map[string]*settingValue),
+	}
+	defaults := Defaults()
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		_, isBool := s.ptr(defaults).(*bool)
+		v := &settingValue{key: s.key, value: s.get(defaults), isBool: isBool}
+		f.values[s.flag] = v
+		fs.Var(v, s.flag, s.usage+" (env "+envName(s.key)+")")
+	}
+	return f
+}
+
+// Load resolves the configuration after the flag set has been parsed.
+func (f *Flags) Load() (*Config, error) {
+	overrides := make(map[string]string)
+	f.fs.Visit(func(fl *flag.Flag) {
+		if v, ok := f.values[fl.Name]; ok {
+			overrides[v.key] = v.value
+		}
+	})
+	return Load(*f.path, *f.profile, overrides)
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bondzai/grextor/internal/embed"
+	"github.com/bondzai/grextor/internal/engine"
+	"github.com/bondzai/grextor/internal/graph"
+	"github.com/bondzai/grextor/internal/vector"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingProvider returns the embedding provider NewEmbedder uses,
+// resolving an empty provider.
+func (c *Config) EmbeddingProvider() string {
+	if c.Embedding.Provider != "" {
+		return c.Embedding.Provider
+	}
+	if c.Embedding.APIKey != "" || c.Embedding.BaseURL != "" {
+		return "openai"
+	}
+	return "hashing"
+}
+
+// NewEmbedder builds the embedder selected by the configuration. Remote
+// embedders are wrapped in a RetryingEmbedder, and in a CachingEmbedder when
+// a cache is configured, so cache hits do not consume the rate budget. Callers should close the
+// result if it implements io.Closer.
+func (c *Config) NewEmbedder() (embed.Embedder, error) {
+	provider := c.EmbeddingProvider()
+
+	var (
+		embedder embed.Embedder
+		model    = c.Embedding.Model
+	)
+	switch provider {
+	case "openai":
+		// Self-hosted gateways often run without authentication.
+		if c.Embedding.APIKey == "" && c.Embedding.BaseURL == "" {
+			return nil, fmt.Errorf("embedding provider openai requires an API key")
+		}
+		if model == "" {
+			model = string(openai.AdaEmbeddingV2)
+		}
+		overflow, err := embed.ParseOverflowStrategy(c.Embedding.Overflow)
+		if err != nil {
+			return nil, err
+		}
+		opts := []embed.OpenAIOption{
+			embed.WithOrganization(c.Embedding.Organization),
+			embed.WithMaxTokens(c.Embedding.MaxTokens, overflow),
+		}
+		if c.Embedding.PreserveNewlines {
+			opts = append(opts, embed.WithPreserveNewlines())
+		}
+		if c.Embedding.BaseURL != "" {
+			opts = append(opts, embed.WithBaseURL(c.Embedding.BaseURL))
+		}
+		if c.Embedding.AzureDeployment != "" {
+			if c.Embedding.BaseURL == "" {
+				return nil, fmt.Errorf("embedding.azure_deployment requires embedding.base_url")
+			}
+			opts = append(opts, embed.WithAzure(c.Embedding.AzureDeployment, c.Embedding.AzureAPIVersion))
+		}
+		if c.Embedding.OutputDimensions > 0 {
+			opts = append(opts, embed.WithDimensions(c.Embedding.OutputDimensions))
+		}
+		embedder = embed.NewRetryingEmbedder(
+			embed.NewOpenAIEmbedder(c.Embedding.APIKey, openai.EmbeddingModel(model), opts...),
+			embed.RetryConfig{
+				MaxAttempts:       c.Retry.MaxAttempts,
+				RequestsPerMinute: c.Retry.RequestsPerMinute,
+				TokensPerMinute:   c.Retry.TokensPerMinute,
+			})
+	case "hashing":
+		// Local and cheap; not worth caching.
+		return embed.NewHashingEmbedder(c.Embedding.Dimensions), nil
+	case "noop":
+		return embed.NewNoOpEmbedder(c.Embedding.Dimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", provider)
+	}
+
+	if c.Cache.Path == "" && c.Cache.MemoryEntries <= 0 {
+		return embedder, nil
+	}
+	var store embed.CacheStore
+	if c.Cache.Path != "" {
+		fc, err := embed.OpenFileCache(c.Cache.Path)
+		if err != nil {
+			return nil, err
+		}
+		store = fc
+	}
+	return embed.NewCachingEmbedder(embedder, c.cacheNamespace(provider, model), store, c.Cache.MemoryEntries), nil
+}
+
+// cacheNamespace names the cache entries of an embedder. It covers every
+// setting that changes the produced vectors: the endpoint and deployment
+// too, since they decide which model actually serves the requests.
+func (c *Config) cacheNamespace(provider, model string) string {
+	ns := provider + "/" + model
+	if c.Embedding.OutputDimensions > 0 {
+		ns = fmt.Sprintf("%s@%d", ns, c.Embedding.OutputDimensions)
+	}
+	if c.Embedding.BaseURL != "" {
+		ns += "+url=" + strings.TrimRight(c.Embedding.BaseURL, "/")
+	}
+	if c.Embedding.AzureDeployment != "" {
+		ns += "+deployment=" + c.Embedding.AzureDeployment
+	}
+	if c.Embedding.PreserveNewlines {
+		ns += "+newlines"
+	}
+	if c.Embedding.Overflow != "" {
+		ns = fmt.Sprintf("%s+%s:%d", ns, c.Embedding.Overflow, c.Embedding.MaxTokens)
+	}
+	return ns
+}
+
+// VectorSize detects the vector size of e and checks it against the
+// configured embedding.dimensions, if any.
+func (c *Config) VectorSize(ctx context.Context, e embed.Embedder) (uint64, error) {
+	dims, err := e.Dimensions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("detecting embedding dimensions: %w", err)
+	}
+	if c.Embedding.Dimensions > 0 && c.Embedding.Dimensions != dims {
+		return 0, fmt.Errorf("embedding.dimensions is %d but the embedder produces %d-dimensional vectors",
+			c.Embedding.Dimensions, dims)
+	}
+	return uint64(dims), nil
+}
+
+// NewVectorStore connects to Qdrant for vectors of the given size.
+func (c *Config) NewVectorStore(size uint64) (*vector.QdrantStore, error) {
+	return vector.NewQdrantStore(c.Qdrant.Addr, c.CollectionName(), size, c.QdrantOptions()...)
+}
+
+// CollectionName returns the document collection: qdrant.collection, or the
+// tenant's own copy of it with collection isolation.
+func (c *Config) CollectionName() string {
+	return c.tenantCollection(c.Qdrant.Collection)
+}
+
+// ImageCollectionName is like CollectionName for image.collection.
+func (c *Config) ImageCollectionName() string {
+	return c.tenantCollection(c.Image.Collection)
+}
+
+func (c *Config) tenantCollection(name string) string {
+	if c.Tenant.Isolation != IsolationCollection {
+		return name
+	}
+	return name + "_" + c.Tenant.ID
+}
+
+// QdrantOptions returns the store options implied by the configuration.
+func (c *Config) QdrantOptions() []vector.QdrantOption {
+	opts := append(c.ConnOptions(), vector.WithCollectionSpec(c.CollectionSpec()))
+	if names := c.VectorNames(); len(names) > 0 {
+		opts = append(opts, vector.WithNamedVectors(names...))
+	}
+	return opts
+}
+
+// ConnOptions returns the Qdrant connection settings shared by all stores:
+// TLS, API key, timeout and keepalive. Durations were checked by Validate.
+func (c *Config) ConnOptions() []vector.QdrantOption {
+	var opts []vector.QdrantOption
+	if c.Qdrant.TLS {
+		opts = append(opts, vector.WithTLS(c.Qdrant.CAFile))
+	}
+	if c.Qdrant.APIKey != "" {
+		opts = append(opts, vector.WithAPIKey(c.Qdrant.APIKey))
+	}
+	if d, err := time.ParseDuration(c.Qdrant.Timeout); err == nil && d > 0 {
+		opts = append(opts, vector.WithTimeout(d))
+	}
+	if d, err := time.ParseDuration(c.Qdrant.Keepalive); err == nil && d > 0 {
+		opts = append(opts, vector.WithKeepalive(d))
+	}
+	return opts
+}
+
+// CollectionSpec returns the collection tuning from the qdrant section.
+func (c *Config) CollectionSpec() vector.CollectionSpec {
+	return vector.CollectionSpec{
+		Distance:        c.Qdrant.Distance,
+		HNSWM:           uint64(c.Qdrant.HNSWM),
+		HNSWEfConstruct: uint64(c.Qdrant.HNSWEfConstruct),
+		OnDisk:          c.Qdrant.OnDisk,
+		Quantization:    c.Qdrant.Quantization,
+		PayloadIndexes:  c.payloadIndexes(c.Qdrant.PayloadIndexes),
+	}
+}
+
+// payloadIndexes adds the keyword index on tenant_id, which every search of
+// a tenant filters on, to indexes.
+func (c *Config) payloadIndexes(indexes map[string]string) map[string]string {
+	if c.Tenant.ID == "" {
+		return indexes
+	}
+	out := map[string]string{engine.TenantKey: "keyword"}
+	for field, typ := range indexes {
+		out[field] = typ
+	}
+	return out
+}
+
+// AddPayloadIndex adds an index of type typ on field to qdrant.payload_indexes
+// unless one is configured, e.g. the content_hash index duplicate lookups
+// filter on.
+func (c *Config) AddPayloadIndex(field, typ string) {
+	if _, ok := c.Qdrant.PayloadIndexes[field]; ok {
+		return
+	}
+	if c.Qdrant.PayloadIndexes == nil {
+		c.Qdrant.PayloadIndexes = make(map[string]string)
+	}
+	c.Qdrant.PayloadIndexes[field] = typ
+}
+
+// VectorNames splits qdrant.vectors into names.
+func (c *Config) VectorNames() []string {
+	var names []string
+	for _, name := range strings.Split(c.Qdrant.Vectors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// NewImageEmbedder returns the configured image embedder, or nil when image
+// support is disabled.
+func (c *Config) NewImageEmbedder() *embed.CLIPEmbedder {
+	if c.Image.URL == "" {
+		return nil
+	}
+	return embed.NewCLIPEmbedder(c.Image.URL, c.Image.Dimensions)
+}
+
+// NewImageStore connects to the Qdrant collection holding image vectors.
+func (c *Config) NewImageStore(size uint64) (*vector.QdrantStore, error) {
+	opts := c.ConnOptions()
+	if indexes := c.payloadIndexes(nil); indexes != nil {
+		opts = append(opts, vector.WithCollectionSpec(vector.CollectionSpec{PayloadIndexes: indexes}))
+	}
+	return vector.NewQdrantStore(c.Qdrant.Addr, c.ImageCollectionName(), size, opts...)
+}
+
+// NewGraphStore connects to Neo4j.
+func (c *Config) NewGraphStore() (*graph.Neo4jStore, error) {
+	var opts []graph.Neo4jOption
+	if c.Neo4j.Token != "" {
+		opts = append(opts, graph.WithBearerAuth(c.Neo4j.Token))
+	}
+	if c.Neo4j.Realm != "" {
+		opts = append(opts, graph.WithRealm(c.Neo4j.Realm))
+	}
+	if c.Neo4j.Database != "" {
+		opts = append(opts, graph.WithDatabase(c.Neo4j.Database))
+	}
+	if c.Neo4j.CAFile != "" {
+		opts = append(opts, graph.WithCAFile(c.Neo4j.CAFile))
+	}
+	if c.Neo4j.MaxPoolSize > 0 {
+		opts = append(opts, graph.WithMaxPoolSize(c.Neo4j.MaxPoolSize))
+	}
+	if c.Neo4j.BatchSize > 0 {
+		opts = append(opts, graph.WithBatchSize(c.Neo4j.BatchSize))
+	}
+	// Durations were checked by Validate.
+	if d, err := time.ParseDuration(c.Neo4j.MaxRetryTime); err == nil && d > 0 {
+		opts = append(opts, graph.WithMaxTransactionRetryTime(d))
+	}
+	if d, err := time.ParseDuration(c.Neo4j.QueryTimeout); err == nil && d > 0 {
+		opts = append(opts, graph.WithQueryTimeout(d))
+	}
+	return graph.NewNeo4jStore(c.Neo4j.URI, c.Neo4j.User, c.Neo4j.Password, opts...)
+}
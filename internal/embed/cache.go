@@ -0,0 +1,308 @@
+package embed
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore persists embeddings by cache key.
+type CacheStore interface {
+	// Get returns the vector stored under key, if any.
+	Get(key string) ([]float32, bool, error)
+	// Put stores vec under key.
+	Put(key string, vec []float32) error
+	Close() error
+}
+
+// CachingEmbedder wraps an Embedder and reuses previously computed vectors.
+// Lookups go through an in-memory LRU first and then the optional
+// persistent store.
+type CachingEmbedder struct {
+	next  Embedder
+	model string
+	store CacheStore
+
+	mu  sync.Mutex
+	lru *lruCache
+}
+
+// NewCachingEmbedder returns a caching decorator for next. model must
+// identify everything that changes the produced vectors (provider, model
+// name, dimensions), since it is part of every cache key. store may be nil
+// for a memory-only cache; memEntries <= 0 disables the in-memory LRU.
+func NewCachingEmbedder(next Embedder, model string, store CacheStore, memEntries int) *CachingEmbedder {
+	return &CachingEmbedder{
+		next:  next,
+		model: model,
+		store: store,
+		lru:   newLRUCache(memEntries),
+	}
+}
+
+func (e *CachingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	key := cacheKey(e.model, text)
+
+	e.mu.Lock()
+	vec, ok := e.lru.get(key)
+	e.mu.Unlock()
+	if ok {
+		return vec, nil
+	}
+
+	if e.store != nil {
+		vec, ok, err := e.store.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading embedding cache: %w", err)
+		}
+		if ok {
+			e.remember(key, vec)
+			return vec, nil
+		}
+	}
+
+	vec, err := e.next.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if e.store != nil {
+		if err := e.store.Put(key, vec); err != nil {
+			return nil, fmt.Errorf("writing embedding cache: %w", err)
+		}
+	}
+	e.remember(key, vec)
+	return vec, nil
+}
+
+func (e *CachingEmbedder) Dimensions(ctx context.Context) (int, error) {
+	return e.next.Dimensions(ctx)
+}
+
+// Close closes the persistent store, if any.
+func (e *CachingEmbedder) Close() error {
+	if e.store == nil {
+		return nil
+	}
+	return e.store.Close()
+}
+
+func (e *CachingEmbedder) remember(key string, vec []float32) {
+	e.mu.Lock()
+	e.lru.add(key, vec)
+	e.mu.Unlock()
+}
+
+// cacheKey hashes the model together with the text. The text is not
+// normalized, as embedders may see whitespace differences, e.g. with
+// preserved newlines.
+func cacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// lruCache is a fixed-size least-recently-used map. It is not safe for
+// concurrent use.
+type lruCache struct {
+	size  int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	vec []float32
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string) ([]float32, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).vec, true
+}
+
+func (c *lruCache) add(key string, vec []float32) {
+	if c.size <= 0 {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).vec = vec
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, vec: vec})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// FileCache is an append-only CacheStore backed by a single file. Each
+// record is a 32-byte key hash, a little-endian uint32 vector length and the
+// float32 components. Only an offset index is kept in memory.
+//
+// Several processes may share the file: writes hold an exclusive lock on it
+// (on Unix) and append at its current end, picking up the records the other
+// processes appended in the meantime.
+type FileCache struct {
+	mu    sync.Mutex
+	f     *os.File
+	size  int64
+	index map[string]int64 // key -> record offset
+}
+
+const fileCacheKeySize = sha256.Size
+
+// OpenFileCache opens or creates the cache file at path. A record left
+// incomplete by a crash is discarded.
+func OpenFileCache(path string) (*FileCache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating embedding cache directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening embedding cache: %w", err)
+	}
+
+	c := &FileCache{f: f, index: make(map[string]int64)}
+	err = c.locked(c.load)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// locked runs fn holding the file lock.
+func (c *FileCache) locked(fn func() error) error {
+	if err := lockFile(c.f); err != nil {
+		return fmt.Errorf("locking embedding cache: %w", err)
+	}
+	err := fn()
+	if unlockErr := unlockFile(c.f); err == nil && unlockErr != nil {
+		err = fmt.Errorf("unlocking embedding cache: %w", unlockErr)
+	}
+	return err
+}
+
+// load indexes the records appended since the last call and truncates a
+// partially written tail, which writers holding the lock never leave behind
+// unless they crash. It must be called holding the file lock.
+func (c *FileCache) load() error {
+	info, err := c.f.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	header := make([]byte, fileCacheKeySize+4)
+	off := c.size
+	for off < total {
+		if _, err := c.f.ReadAt(header, off); err != nil {
+			break
+		}
+		n := int64(binary.LittleEndian.Uint32(header[fileCacheKeySize:]))
+		end := off + int64(len(header)) + n*4
+		if end > total {
+			break
+		}
+		c.index[hex.EncodeToString(header[:fileCacheKeySize])] = off
+		off = end
+	}
+
+	if off < total {
+		if err := c.f.Truncate(off); err != nil {
+			return fmt.Errorf("repairing embedding cache: %w", err)
+		}
+	}
+	c.size = off
+	return nil
+}
+
+func (c *FileCache) Get(key string) ([]float32, bool, error) {
+	c.mu.Lock()
+	off, ok := c.index[key]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	header := make([]byte, fileCacheKeySize+4)
+	if _, err := c.f.ReadAt(header, off); err != nil {
+		return nil, false, err
+	}
+	// A record that does not carry the key is treated as a miss.
+	if hex.EncodeToString(header[:fileCacheKeySize]) != key {
+		return nil, false, nil
+	}
+	n := binary.LittleEndian.Uint32(header[fileCacheKeySize:])
+	raw := make([]byte, int(n)*4)
+	if _, err := c.f.ReadAt(raw, off+int64(len(header))); err != nil {
+		return nil, false, err
+	}
+
+	vec := make([]float32, n)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return vec, true, nil
+}
+
+func (c *FileCache) Put(key string, vec []float32) error {
+	hash, err := hex.DecodeString(key)
+	if err != nil || len(hash) != fileCacheKeySize {
+		return fmt.Errorf("invalid cache key %q", key)
+	}
+
+	rec := make([]byte, fileCacheKeySize+4+len(vec)*4)
+	copy(rec, hash)
+	binary.LittleEndian.PutUint32(rec[fileCacheKeySize:], uint32(len(vec)))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(rec[fileCacheKeySize+4+i*4:], math.Float32bits(v))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.index[key]; ok {
+		return nil
+	}
+	return c.locked(func() error {
+		// Other processes may have appended since; index their records.
+		if err := c.load(); err != nil {
+			return err
+		}
+		if _, ok := c.index[key]; ok {
+			return nil
+		}
+		if _, err := c.f.Write(rec); err != nil {
+			return err
+		}
+		c.index[key] = c.size
+		c.size += int64(len(rec))
+		return nil
+	})
+}
+
+// Len returns the number of cached vectors.
+func (c *FileCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.index)
+}
+
+func (c *FileCache) Close() error {
+	return c.f.Close()
+}
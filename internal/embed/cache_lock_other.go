@@ -0,0 +1,11 @@
+//go:build !unix
+
+package embed
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable; processes must not share
+// a cache file there.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,164 @@
+package embed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// countingEmbedder returns a vector derived from the text length and counts calls.
+type countingEmbedder struct {
+	calls int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.calls++
+	return []float32{float32(len(text)), 0.5}, nil
+}
+
+func (e *countingEmbedder) Dimensions(ctx context.Context) (int, error) {
+	return 2, nil
+}
+
+func TestCachingEmbedder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "embeddings.cache")
+
+	t.Run("MemoryAndDisk", func(t *testing.T) {
+		store, err := OpenFileCache(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		next := &countingEmbedder{}
+		e := NewCachingEmbedder(next, "model-a", store, 10)
+
+		first, _ := e.Embed(ctx, "hello")
+		second, _ := e.Embed(ctx, "hello")
+		if next.calls != 1 {
+			t.Errorf("expected 1 call, got %d", next.calls)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("expected cached vector %v, got %v", first, second)
+		}
+		// Whitespace may matter to the embedder, so it is part of the key.
+		if _, err := e.Embed(ctx, "  hello\r\n"); err != nil || next.calls != 2 {
+			t.Errorf("expected a miss for different whitespace, got %d calls (%v)", next.calls, err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		store, err := OpenFileCache(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer store.Close()
+		next := &countingEmbedder{}
+		e := NewCachingEmbedder(next, "model-a", store, 0)
+
+		vec, err := e.Embed(ctx, "hello")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if next.calls != 0 {
+			t.Errorf("expected disk hit, got %d calls", next.calls)
+		}
+		if want := []float32{5, 0.5}; !reflect.DeepEqual(vec, want) {
+			t.Errorf("expected %v, got %v", want, vec)
+		}
+
+		// A different model must not share entries.
+		other := NewCachingEmbedder(next, "model-b", store, 0)
+		if _, err := other.Embed(ctx, "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if next.calls != 1 {
+			t.Errorf("expected miss for other model, got %d calls", next.calls)
+		}
+	})
+
+	t.Run("TruncatedTail", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte{1, 2, 3})
+		f.Close()
+
+		store, err := OpenFileCache(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer store.Close()
+		if store.Len() != 3 {
+			t.Errorf("expected 3 entries, got %d", store.Len())
+		}
+	})
+
+	t.Run("SharedFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "shared.cache")
+		a, err := OpenFileCache(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer a.Close()
+		b, err := OpenFileCache(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+
+		keyA, keyB := cacheKey("m", "a"), cacheKey("m", "b")
+		if err := a.Put(keyA, []float32{1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Put(keyB, []float32{2}); err != nil {
+			t.Fatal(err)
+		}
+		for _, store := range []*FileCache{a, b} {
+			if vec, ok, err := store.Get(keyB); err != nil || (ok && vec[0] != 2) {
+				t.Errorf("expected keyB to be missing or 2, got %v (%v)", vec, err)
+			}
+		}
+		if vec, ok, _ := b.Get(keyA); !ok || vec[0] != 1 {
+			t.Errorf("expected b to find the record a appended, got %v", vec)
+		}
+
+		reopened, err := OpenFileCache(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close()
+		for key, want := range map[string]float32{keyA: 1, keyB: 2} {
+			if vec, ok, _ := reopened.Get(key); !ok || vec[0] != want {
+				t.Errorf("expected %v for %s, got %v", want, key, vec)
+			}
+		}
+
+		// A record under another key is never returned.
+		keyC := cacheKey("m", "c")
+		reopened.index[keyC] = reopened.index[keyA]
+		if vec, ok, err := reopened.Get(keyC); ok || err != nil {
+			t.Errorf("expected a miss for a mismatched record, got %v (%v)", vec, err)
+		}
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+	c.add("a", []float32{1})
+	c.add("b", []float32{2})
+	c.get("a")
+	c.add("c", []float32{3})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected a to be retained")
+	}
+}
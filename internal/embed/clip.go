@@ -0,0 +1,123 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CLIPEmbedder embeds images and text through a local CLIP-style model
+// server. The server accepts
+//
+//	POST {baseURL}/embed {"image": "<base64>", "mime_type": "image/png"}
+//	POST {baseURL}/embed {"text": "a sequence diagram"}
+//
+// and answers {"embedding": [...]}. Images and text share one vector space,
+// so text queries find matching images.
+type CLIPEmbedder struct {
+	baseURL string
+	client  *http.Client
+
+	dimsMu sync.Mutex
+	dims   int
+}
+
+// NewCLIPEmbedder talks to the model server at baseURL, e.g.
+// "http://localhost:8081". dims is the known vector size; zero probes the
+// server once.
+func NewCLIPEmbedder(baseURL string, dims int) *CLIPEmbedder {
+	return &CLIPEmbedder{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: time.Minute},
+		dims:    dims,
+	}
+}
+
+type clipRequest struct {
+	Image    string `json:"image,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+type clipResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// EmbedImage implements ImageEmbedder.
+func (e *CLIPEmbedder) EmbedImage(ctx context.Context, data []byte, mimeType string) ([]float32, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty image")
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return e.embed(ctx, clipRequest{Image: base64.StdEncoding.EncodeToString(data), MIMEType: mimeType})
+}
+
+// Embed implements Embedder with the text tower of the model.
+func (e *CLIPEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return e.embed(ctx, clipRequest{Text: text})
+}
+
+// Dimensions returns the configured vector size, or probes the server once.
+func (e *CLIPEmbedder) Dimensions(ctx context.Context) (int, error) {
+	e.dimsMu.Lock()
+	defer e.dimsMu.Unlock()
+	if e.dims > 0 {
+		return e.dims, nil
+	}
+
+	vec, err := e.Embed(ctx, "dimension probe")
+	if err != nil {
+		return 0, fmt.Errorf("probing dimensions of %s: %w", e.baseURL, err)
+	}
+	e.dims = len(vec)
+	return e.dims, nil
+}
+
+func (e *CLIPEmbedder) embed(ctx context.Context, body clipRequest) ([]float32, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embed", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling model server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out clipResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&out); err != nil && resp.StatusCode < http.StatusBadRequest {
+		return nil, fmt.Errorf("decoding model server response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg := out.Error
+		if msg == "" {
+			msg = resp.Status
+		}
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Err:        errors.New(msg),
+		}
+	}
+	if len(out.Embedding) == 0 {
+		return nil, errors.New("model server returned an empty embedding")
+	}
+	return out.Embedding, nil
+}
@@ -0,0 +1,82 @@
+package embed
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newCLIPStandIn embeds images as [len(bytes), 1] and text as [len(text), 0].
+func newCLIPStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embed" {
+			http.NotFound(w, r)
+			return
+		}
+		var req clipRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(clipResponse{Error: err.Error()})
+			return
+		}
+		switch {
+		case req.Image != "":
+			data, err := base64.StdEncoding.DecodeString(req.Image)
+			if err != nil || req.MIMEType == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(clipResponse{Error: "bad image"})
+				return
+			}
+			json.NewEncoder(w).Encode(clipResponse{Embedding: []float32{float32(len(data)), 1}})
+		case req.Text == "overload":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(clipResponse{Error: "busy"})
+		default:
+			json.NewEncoder(w).Encode(clipResponse{Embedding: []float32{float32(len(req.Text)), 0}})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCLIPEmbedder(t *testing.T) {
+	ctx := context.Background()
+	e := NewCLIPEmbedder(newCLIPStandIn(t).URL+"/", 0)
+
+	png := []byte("\x89PNG\r\n\x1a\n....")
+	vec, err := e.EmbedImage(ctx, png, "")
+	if err != nil {
+		t.Fatalf("EmbedImage: %v", err)
+	}
+	if vec[0] != float32(len(png)) || vec[1] != 1 {
+		t.Errorf("unexpected image vector %v", vec)
+	}
+
+	vec, err = e.Embed(ctx, "diagram")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if vec[0] != 7 || vec[1] != 0 {
+		t.Errorf("unexpected text vector %v", vec)
+	}
+
+	dims, err := e.Dimensions(ctx)
+	if err != nil || dims != 2 {
+		t.Errorf("expected probed dimensions 2, got %d (%v)", dims, err)
+	}
+
+	_, err = e.Embed(ctx, "overload")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || !IsRetryable(err) {
+		t.Errorf("expected retryable 503 APIError, got %v", err)
+	}
+
+	if _, err := e.EmbedImage(ctx, nil, "image/png"); err == nil {
+		t.Error("expected error for an empty image")
+	}
+}
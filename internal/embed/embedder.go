@@ -6,4 +6,16 @@ import "context"
 type Embedder interface {
 	// Embed generates a vector embedding for the given text.
 	Embed(ctx context.Context, text string) ([]float32, error)
+	// Dimensions returns the size of the vectors produced by Embed.
+	Dimensions(ctx context.Context) (int, error)
+}
+
+// ImageEmbedder generates embeddings from encoded images, such as PNG or
+// JPEG bytes. CLIP-style models embed text into the same space, so their
+// implementations usually satisfy Embedder too.
+type ImageEmbedder interface {
+	// EmbedImage generates a vector embedding for the given image.
+	EmbedImage(ctx context.Context, data []byte, mimeType string) ([]float32, error)
+	// Dimensions returns the size of the vectors produced by EmbedImage.
+	Dimensions(ctx context.Context) (int, error)
 }
@@ -0,0 +1,81 @@
+package embed
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// HashingEmbedder produces deterministic, L2-normalized vectors using the
+// hashing trick over word tokens and character trigrams. It needs no model or
+// network access and gives meaningful lexical similarity, which makes it
+// suitable for offline development, demos and tests.
+type HashingEmbedder struct {
+	dims int
+}
+
+// Weights of the two feature families; words dominate, trigrams add
+// robustness to inflections and typos.
+const (
+	hashingWordWeight    = 1.0
+	hashingTrigramWeight = 0.5
+)
+
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	if dims <= 0 {
+		dims = 1536 // Same default as NoOpEmbedder
+	}
+	return &HashingEmbedder{dims: dims}
+}
+
+func (e *HashingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	acc := make([]float64, e.dims)
+	for _, word := range tokenize(text) {
+		e.add(acc, "w:"+word, hashingWordWeight)
+
+		padded := []rune("^" + word + "$")
+		for i := 0; i+3 <= len(padded); i++ {
+			e.add(acc, "c:"+string(padded[i:i+3]), hashingTrigramWeight)
+		}
+	}
+
+	var norm float64
+	for _, v := range acc {
+		norm += v * v
+	}
+	vec := make([]float32, e.dims)
+	if norm == 0 {
+		return vec, nil
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range acc {
+		vec[i] = float32(v / norm)
+	}
+	return vec, nil
+}
+
+func (e *HashingEmbedder) Dimensions(ctx context.Context) (int, error) {
+	return e.dims, nil
+}
+
+// add hashes feature into one bucket. The sign comes from an independent bit
+// of the hash so that collisions cancel out on average instead of adding up.
+func (e *HashingEmbedder) add(acc []float64, feature string, weight float64) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	acc[(sum&(1<<63-1))%uint64(e.dims)] += weight
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,46 @@
+package embed
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
+
+func TestHashingEmbedder(t *testing.T) {
+	ctx := context.Background()
+	e := NewHashingEmbedder(256)
+
+	query, _ := e.Embed(ctx, "How do I restart the database?")
+	related, _ := e.Embed(ctx, "Restarting the database server safely")
+	unrelated, _ := e.Embed(ctx, "Quarterly marketing budget review")
+
+	if len(query) != 256 {
+		t.Fatalf("expected 256 dimensions, got %d", len(query))
+	}
+	if n := math.Sqrt(dot(query, query)); math.Abs(n-1) > 1e-5 {
+		t.Errorf("expected unit norm, got %v", n)
+	}
+	if dot(query, related) <= dot(query, unrelated) {
+		t.Errorf("expected related text to score higher: related=%v unrelated=%v",
+			dot(query, related), dot(query, unrelated))
+	}
+
+	again, _ := NewHashingEmbedder(256).Embed(ctx, "How do I restart the database?")
+	if !reflect.DeepEqual(query, again) {
+		t.Error("expected deterministic vectors")
+	}
+
+	empty, _ := e.Embed(ctx, "  ")
+	if dot(empty, empty) != 0 {
+		t.Error("expected zero vector for empty text")
+	}
+}
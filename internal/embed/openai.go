@@ -2,42 +2,283 @@ package embed
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 )
 
+// knownMaxTokens lists the input limit of models whose limit is known.
+var knownMaxTokens = map[openai.EmbeddingModel]int{
+	openai.AdaEmbeddingV2:  8191,
+	openai.SmallEmbedding3: 8191,
+	openai.LargeEmbedding3: 8191,
+}
+
+// knownDimensions lists the output size of models that need no probing.
+var knownDimensions = map[openai.EmbeddingModel]int{
+	openai.AdaEmbeddingV2:  1536,
+	openai.SmallEmbedding3: 1536,
+	openai.LargeEmbedding3: 3072,
+}
+
 type OpenAIEmbedder struct {
 	client *openai.Client
 	model  openai.EmbeddingModel
+	// outputDims is sent as the "dimensions" request parameter when set.
+	outputDims int
+
+	maxTokens        int
+	overflow         OverflowStrategy
+	tokenizer        Tokenizer
+	preserveNewlines bool
+
+	dimsMu sync.Mutex
+	dims   int
+}
+
+// OpenAIOption customises an OpenAIEmbedder, typically to talk to an
+// OpenAI-compatible gateway instead of api.openai.com.
+type OpenAIOption func(*openAIOptions)
+
+type openAIOptions struct {
+	baseURL         string
+	organization    string
+	azure           bool
+	azureAPIVersion string
+	azureDeployment string
+	httpClient      openai.HTTPDoer
+	dimensions      int
+
+	maxTokens        int
+	overflow         OverflowStrategy
+	tokenizer        Tokenizer
+	preserveNewlines bool
+}
+
+// WithBaseURL sends requests to an OpenAI-compatible API such as vLLM or
+// LocalAI, e.g. "http://gateway:8000/v1".
+func WithBaseURL(url string) OpenAIOption {
+	return func(o *openAIOptions) { o.baseURL = url }
+}
+
+// WithOrganization sets the OpenAI-Organization header.
+func WithOrganization(org string) OpenAIOption {
+	return func(o *openAIOptions) { o.organization = org }
+}
+
+// WithAzure targets an Azure OpenAI resource. The base URL must be set with
+// WithBaseURL to the resource endpoint. deployment names the Azure deployment
+// and defaults to the model name; an empty apiVersion keeps the client default.
+func WithAzure(deployment, apiVersion string) OpenAIOption {
+	return func(o *openAIOptions) {
+		o.azure = true
+		o.azureDeployment = deployment
+		o.azureAPIVersion = apiVersion
+	}
 }
 
-func NewOpenAIEmbedder(apiKey string, model openai.EmbeddingModel) *OpenAIEmbedder {
+// WithHTTPClient replaces the HTTP client, e.g. to add proxies or custom TLS.
+func WithHTTPClient(c openai.HTTPDoer) OpenAIOption {
+	return func(o *openAIOptions) { o.httpClient = c }
+}
+
+// WithDimensions requests shortened embeddings from models that support the
+// "dimensions" parameter, such as text-embedding-3-*.
+func WithDimensions(n int) OpenAIOption {
+	return func(o *openAIOptions) { o.dimensions = n }
+}
+
+// WithMaxTokens sets the input token limit and what to do with longer
+// inputs. The limit defaults to the model's context size when known; a
+// negative limit disables the check. The strategy defaults to OverflowError,
+// but without a limit, strategy or WithTokenizer nothing is checked locally,
+// as the approximate count would reject inputs the API accepts.
+func WithMaxTokens(n int, strategy OverflowStrategy) OpenAIOption {
+	return func(o *openAIOptions) {
+		o.maxTokens = n
+		o.overflow = strategy
+	}
+}
+
+// WithTokenizer replaces the ApproxTokenizer used for length checks, e.g.
+// with an exact tokenizer for the model.
+func WithTokenizer(t Tokenizer) OpenAIOption {
+	return func(o *openAIOptions) { o.tokenizer = t }
+}
+
+// WithPreserveNewlines sends newlines unchanged instead of replacing them
+// with spaces, which suits code and other structured text.
+func WithPreserveNewlines() OpenAIOption {
+	return func(o *openAIOptions) { o.preserveNewlines = true }
+}
+
+func NewOpenAIEmbedder(apiKey string, model openai.EmbeddingModel, opts ...OpenAIOption) *OpenAIEmbedder {
 	if model == "" {
 		model = openai.AdaEmbeddingV2
 	}
+
+	var o openAIOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg := openai.DefaultConfig(apiKey)
+	if o.azure {
+		cfg = openai.DefaultAzureConfig(apiKey, o.baseURL)
+		if o.azureAPIVersion != "" {
+			cfg.APIVersion = o.azureAPIVersion
+		}
+		if o.azureDeployment != "" {
+			deployment := o.azureDeployment
+			cfg.AzureModelMapperFunc = func(string) string { return deployment }
+		}
+	} else if o.baseURL != "" {
+		cfg.BaseURL = strings.TrimSuffix(o.baseURL, "/")
+	}
+	cfg.OrgID = o.organization
+	if o.httpClient != nil {
+		cfg.HTTPClient = o.httpClient
+	}
+	cfg.HTTPClient = &retryAfterDoer{next: cfg.HTTPClient}
+
+	if o.overflow == "" {
+		// ApproxTokenizer overestimates, so leave the default limit to the API.
+		if o.maxTokens == 0 && o.tokenizer == nil {
+			o.maxTokens = -1
+		}
+		o.overflow = OverflowError
+	}
+	if o.maxTokens == 0 {
+		o.maxTokens = knownMaxTokens[model]
+	}
+	if o.tokenizer == nil {
+		o.tokenizer = ApproxTokenizer{}
+	}
+
 	return &OpenAIEmbedder{
-		client: openai.NewClient(apiKey),
-		model:  model,
+		client:           openai.NewClientWithConfig(cfg),
+		model:            model,
+		outputDims:       o.dimensions,
+		maxTokens:        o.maxTokens,
+		overflow:         o.overflow,
+		tokenizer:        o.tokenizer,
+		preserveNewlines: o.preserveNewlines,
 	}
 }
 
 func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
-	text = strings.ReplaceAll(text, "\n", " ")
+	if !e.preserveNewlines {
+		text = strings.ReplaceAll(text, "\n", " ")
+	}
+	chunks, weights, err := fitInput(text, e.maxTokens, e.overflow, e.tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
 	req := openai.EmbeddingRequest{
-		Input: []string{text},
-		Model: e.model,
+		Input:      chunks,
+		Model:      e.model,
+		Dimensions: e.outputDims,
 	}
 
-	resp, err := e.client.CreateEmbeddings(ctx, req)
+	var retryAfter time.Duration
+	resp, err := e.client.CreateEmbeddings(context.WithValue(ctx, retryAfterKey{}, &retryAfter), req)
 	if err != nil {
-		return nil, fmt.Errorf("creating embeddings: %w", err)
+		return nil, fmt.Errorf("creating embeddings: %w", wrapOpenAIError(err, retryAfter))
 	}
 
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
+	if len(resp.Data) != len(chunks) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(chunks), len(resp.Data))
 	}
 
-	return resp.Data[0].Embedding, nil
+	vectors := make([][]float32, len(chunks))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return weightedAverage(vectors, weights), nil
+}
+
+// Dimensions returns the vector size of the configured model. Unknown models
+// are probed with a single embedding request whose result is cached.
+func (e *OpenAIEmbedder) Dimensions(ctx context.Context) (int, error) {
+	if e.outputDims > 0 {
+		return e.outputDims, nil
+	}
+	if dims, ok := knownDimensions[e.model]; ok {
+		return dims, nil
+	}
+
+	e.dimsMu.Lock()
+	defer e.dimsMu.Unlock()
+	if e.dims > 0 {
+		return e.dims, nil
+	}
+
+	vec, err := e.Embed(ctx, "dimension probe")
+	if err != nil {
+		return 0, fmt.Errorf("probing dimensions of %s: %w", e.model, err)
+	}
+	e.dims = len(vec)
+	return e.dims, nil
+}
+
+// retryAfterKey carries a *time.Duration through the request context so the
+// HTTP layer can report the Retry-After header of a failed call.
+type retryAfterKey struct{}
+
+// retryAfterDoer records the Retry-After header of error responses.
+type retryAfterDoer struct {
+	next openai.HTTPDoer
+}
+
+func (d *retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.next.Do(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if dst, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+		*dst = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, nil
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date forms.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// wrapOpenAIError converts client errors carrying an HTTP status to *APIError.
+func wrapOpenAIError(err error, retryAfter time.Duration) error {
+	var (
+		apiErr *openai.APIError
+		reqErr *openai.RequestError
+		status int
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	default:
+		return err
+	}
+	return &APIError{StatusCode: status, RetryAfter: retryAfter, Err: err}
 }
@@ -0,0 +1,91 @@
+package embed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captureServer answers every request with a 3-dimensional embedding and
+// records the last request and its decoded body.
+func captureServer(t *testing.T) (*httptest.Server, *http.Request, map[string]interface{}) {
+	t.Helper()
+	var (
+		last = &http.Request{}
+		body = make(map[string]interface{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = *r.Clone(context.Background())
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"data": []map[string]interface{}{
+				{"object": "embedding", "index": 0, "embedding": []float32{1, 2, 3}},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, last, body
+}
+
+func TestOpenAIEmbedder_Options(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("CompatibleGateway", func(t *testing.T) {
+		srv, last, body := captureServer(t)
+		e := NewOpenAIEmbedder("key", "text-embedding-3-large",
+			WithBaseURL(srv.URL+"/v1/"),
+			WithOrganization("org-1"),
+			WithDimensions(3),
+			WithHTTPClient(srv.Client()),
+		)
+
+		if _, err := e.Embed(ctx, "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if last.URL.Path != "/v1/embeddings" {
+			t.Errorf("expected /v1/embeddings, got %s", last.URL.Path)
+		}
+		if got := last.Header.Get("OpenAI-Organization"); got != "org-1" {
+			t.Errorf("expected organization header, got %q", got)
+		}
+		if body["dimensions"] != float64(3) {
+			t.Errorf("expected dimensions 3 in request, got %v", body["dimensions"])
+		}
+		if dims, _ := e.Dimensions(ctx); dims != 3 {
+			t.Errorf("expected 3 dimensions, got %d", dims)
+		}
+	})
+
+	t.Run("Azure", func(t *testing.T) {
+		srv, last, _ := captureServer(t)
+		e := NewOpenAIEmbedder("key", "text-embedding-3-small",
+			WithBaseURL(srv.URL),
+			WithAzure("my-deployment", "2024-02-01"),
+		)
+
+		if _, err := e.Embed(ctx, "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if last.URL.Path != "/openai/deployments/my-deployment/embeddings" {
+			t.Errorf("unexpected path %s", last.URL.Path)
+		}
+		if got := last.URL.Query().Get("api-version"); got != "2024-02-01" {
+			t.Errorf("expected api-version 2024-02-01, got %q", got)
+		}
+		if got := last.Header.Get("api-key"); got != "key" {
+			t.Errorf("expected api-key header, got %q", got)
+		}
+	})
+
+	t.Run("ProbesUnknownModel", func(t *testing.T) {
+		srv, _, _ := captureServer(t)
+		e := NewOpenAIEmbedder("key", "local-model", WithBaseURL(srv.URL+"/v1"))
+		if dims, err := e.Dimensions(ctx); err != nil || dims != 3 {
+			t.Errorf("expected 3 dimensions, got %d (%v)", dims, err)
+		}
+	})
+}
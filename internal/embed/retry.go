@@ -0,0 +1,243 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// APIError is returned by embedders backed by an HTTP API when a call failed
+// with an HTTP status.
+type APIError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the server, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is transient: rate limiting, server errors
+// and network failures. Cancellation and other client errors are permanent.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests,
+			apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode >= http.StatusInternalServerError:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
+
+// RetryConfig tunes a RetryingEmbedder. Zero values select the defaults.
+type RetryConfig struct {
+	// MaxAttempts is the total number of calls per Embed, including the first.
+	// Defaults to 5.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles after every
+	// attempt. Defaults to 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff. Defaults to 30s.
+	MaxDelay time.Duration
+	// RequestsPerMinute limits calls to the wrapped embedder. Zero disables it.
+	RequestsPerMinute int
+	// TokensPerMinute limits the estimated input tokens sent per minute.
+	// Zero disables it.
+	TokensPerMinute int
+	// IsRetryable classifies errors. Defaults to IsRetryable.
+	IsRetryable func(error) bool
+	// EstimateTokens estimates the tokens of an input. Defaults to one token
+	// per four bytes.
+	EstimateTokens func(string) int
+}
+
+// RetryingEmbedder wraps an Embedder with rate limiting and retries using
+// exponential backoff with full jitter. A Retry-After delay reported through
+// *APIError takes precedence over the computed backoff.
+type RetryingEmbedder struct {
+	next     Embedder
+	cfg      RetryConfig
+	requests *rateLimiter
+	tokens   *rateLimiter
+
+	// sleep waits for d or until ctx is done; replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func NewRetryingEmbedder(next Embedder, cfg RetryConfig) *RetryingEmbedder {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 500 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 30 * time.Second
+	}
+	if cfg.IsRetryable == nil {
+		cfg.IsRetryable = IsRetryable
+	}
+	if cfg.EstimateTokens == nil {
+		cfg.EstimateTokens = func(s string) int { return len(s)/4 + 1 }
+	}
+	return &RetryingEmbedder{
+		next:     next,
+		cfg:      cfg,
+		requests: newRateLimiter(cfg.RequestsPerMinute),
+		tokens:   newRateLimiter(cfg.TokensPerMinute),
+		sleep:    sleepContext,
+	}
+}
+
+func (e *RetryingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	var vec []float32
+	err := e.do(ctx, e.cfg.EstimateTokens(text), func() error {
+		var err error
+		vec, err = e.next.Embed(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+// Dimensions retries as well, since it may probe the wrapped embedder.
+func (e *RetryingEmbedder) Dimensions(ctx context.Context) (int, error) {
+	var dims int
+	err := e.do(ctx, 1, func() error {
+		var err error
+		dims, err = e.next.Dimensions(ctx)
+		return err
+	})
+	return dims, err
+}
+
+func (e *RetryingEmbedder) do(ctx context.Context, tokens int, call func() error) error {
+	var err error
+	for attempt := 0; attempt < e.cfg.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if werr := e.sleep(ctx, e.backoff(attempt, err)); werr != nil {
+				return fmt.Errorf("%w (last error: %v)", werr, err)
+			}
+		}
+		if werr := e.requests.wait(ctx, 1, e.sleep); werr != nil {
+			return werr
+		}
+		if werr := e.tokens.wait(ctx, tokens, e.sleep); werr != nil {
+			return werr
+		}
+
+		if err = call(); err == nil || !e.cfg.IsRetryable(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", e.cfg.MaxAttempts, err)
+}
+
+// backoff returns the delay before the given retry attempt (1-based).
+func (e *RetryingEmbedder) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	ceiling := e.cfg.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > e.cfg.MaxDelay {
+		ceiling = e.cfg.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// rateLimiter is a token bucket holding up to one minute of budget. A nil
+// limiter never waits.
+type rateLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	perSec   float64
+	avail    float64
+	last     time.Time
+	now      func() time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		capacity: float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		avail:    float64(perMinute),
+		now:      time.Now,
+	}
+}
+
+// wait reserves n units, sleeping until the budget allows it. Requests larger
+// than the whole budget are clamped so they can eventually proceed.
+func (l *rateLimiter) wait(ctx context.Context, n int, sleep func(context.Context, time.Duration) error) error {
+	if l == nil {
+		return nil
+	}
+	want := float64(n)
+	if want > l.capacity {
+		want = l.capacity
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.avail += now.Sub(l.last).Seconds() * l.perSec
+		if l.avail > l.capacity {
+			l.avail = l.capacity
+		}
+	}
+	l.last = now
+	l.avail -= want
+	var delay time.Duration
+	if l.avail < 0 {
+		delay = time.Duration(-l.avail / l.perSec * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := sleep(ctx, delay); err != nil {
+		l.mu.Lock()
+		l.avail += want
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
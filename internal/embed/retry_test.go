@@ -0,0 +1,171 @@
+package embed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newOpenAIStandIn serves /v1/embeddings, answering the first failures
+// requests with the given status and Retry-After header.
+func newOpenAIStandIn(t *testing.T, failures int, status int, retryAfter string) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if int(n) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error":{"message":"stand-in failure","type":"test"}}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"object": "list",
+			"model":  "test-model",
+			"data": []map[string]interface{}{
+				{"object": "embedding", "index": 0, "embedding": []float32{0.1, 0.2}},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func newTestOpenAIEmbedder(srv *httptest.Server) *OpenAIEmbedder {
+	return NewOpenAIEmbedder("test-key", "test-model", WithBaseURL(srv.URL+"/v1"))
+}
+
+// recordSleeps replaces the embedder's sleep with one that records delays.
+func recordSleeps(e *RetryingEmbedder) *[]time.Duration {
+	var delays []time.Duration
+	e.sleep = func(ctx context.Context, d time.Duration) error {
+		if d > 0 {
+			delays = append(delays, d)
+		}
+		return ctx.Err()
+	}
+	return &delays
+}
+
+func TestRetryingEmbedder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("RetriesRateLimitWithRetryAfter", func(t *testing.T) {
+		srv, calls := newOpenAIStandIn(t, 2, http.StatusTooManyRequests, "3")
+		e := NewRetryingEmbedder(newTestOpenAIEmbedder(srv), RetryConfig{})
+		delays := recordSleeps(e)
+
+		vec, err := e.Embed(ctx, "hello")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(vec) != 2 {
+			t.Errorf("expected 2 dimensions, got %d", len(vec))
+		}
+		if *calls != 3 {
+			t.Errorf("expected 3 calls, got %d", *calls)
+		}
+		if len(*delays) != 2 || (*delays)[0] != 3*time.Second {
+			t.Errorf("expected two 3s delays, got %v", *delays)
+		}
+	})
+
+	t.Run("RetriesServerErrorsWithBackoff", func(t *testing.T) {
+		srv, calls := newOpenAIStandIn(t, 3, http.StatusServiceUnavailable, "")
+		e := NewRetryingEmbedder(newTestOpenAIEmbedder(srv), RetryConfig{BaseDelay: time.Second, MaxDelay: 2 * time.Second})
+		delays := recordSleeps(e)
+
+		if _, err := e.Embed(ctx, "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *calls != 4 {
+			t.Errorf("expected 4 calls, got %d", *calls)
+		}
+		for _, d := range *delays {
+			if d > 2*time.Second {
+				t.Errorf("delay %v exceeds MaxDelay", d)
+			}
+		}
+	})
+
+	t.Run("PermanentError", func(t *testing.T) {
+		srv, calls := newOpenAIStandIn(t, 1, http.StatusUnauthorized, "")
+		e := NewRetryingEmbedder(newTestOpenAIEmbedder(srv), RetryConfig{})
+		recordSleeps(e)
+
+		_, err := e.Embed(ctx, "hello")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401 APIError, got %v", err)
+		}
+		if *calls != 1 {
+			t.Errorf("expected 1 call, got %d", *calls)
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		srv, calls := newOpenAIStandIn(t, 10, http.StatusInternalServerError, "")
+		e := NewRetryingEmbedder(newTestOpenAIEmbedder(srv), RetryConfig{MaxAttempts: 3})
+		recordSleeps(e)
+
+		if _, err := e.Embed(ctx, "hello"); err == nil {
+			t.Error("expected error, got nil")
+		}
+		if *calls != 3 {
+			t.Errorf("expected 3 calls, got %d", *calls)
+		}
+	})
+
+	t.Run("TokenBudget", func(t *testing.T) {
+		srv, _ := newOpenAIStandIn(t, 0, 0, "")
+		e := NewRetryingEmbedder(newTestOpenAIEmbedder(srv), RetryConfig{
+			TokensPerMinute: 60,
+			EstimateTokens:  func(string) int { return 30 },
+		})
+		delays := recordSleeps(e)
+		now := time.Unix(0, 0)
+		e.tokens.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			if _, err := e.Embed(ctx, "hello"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		// The third call exceeds the 60 token budget by 30 tokens = 30s at 1 token/s.
+		if len(*delays) != 1 || (*delays)[0] != 30*time.Second {
+			t.Errorf("expected one 30s delay, got %v", *delays)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"0.5", 500 * time.Millisecond},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{"garbage", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
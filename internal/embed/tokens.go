@@ -0,0 +1,173 @@
+package embed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInputTooLong is returned when an input exceeds the model's token limit
+// and the overflow strategy is OverflowError.
+var ErrInputTooLong = errors.New("input exceeds token limit")
+
+// Tokenizer splits text into model tokens.
+type Tokenizer interface {
+	// Tokenize returns the tokens of text; concatenating them yields text.
+	Tokenize(text string) []string
+}
+
+// OverflowStrategy decides what happens to inputs longer than the limit.
+type OverflowStrategy string
+
+const (
+	// OverflowError rejects the input with ErrInputTooLong.
+	OverflowError OverflowStrategy = "error"
+	// OverflowTruncateTail drops the end of the input.
+	OverflowTruncateTail OverflowStrategy = "truncate_tail"
+	// OverflowTruncateHead drops the beginning of the input.
+	OverflowTruncateHead OverflowStrategy = "truncate_head"
+	// OverflowSplitAverage embeds consecutive chunks and averages the vectors,
+	// weighted by chunk length.
+	OverflowSplitAverage OverflowStrategy = "split_average"
+)
+
+// ParseOverflowStrategy validates a strategy name; empty leaves the choice to
+// the embedder.
+func ParseOverflowStrategy(s string) (OverflowStrategy, error) {
+	switch st := OverflowStrategy(s); st {
+	case "", OverflowError, OverflowTruncateTail, OverflowTruncateHead, OverflowSplitAverage:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown overflow strategy %q", s)
+	}
+}
+
+// ApproxTokenizer approximates BPE tokenizers such as cl100k without needing
+// their vocabulary: every run of punctuation is a token, and letters/digits
+// form tokens of at most four characters, with preceding whitespace attached.
+// It tends to overestimate, which keeps inputs safely below the real limit.
+type ApproxTokenizer struct{}
+
+func (ApproxTokenizer) Tokenize(text string) []string {
+	const maxWordToken = 4
+
+	var tokens []string
+	start, runes := 0, 0
+	prevClass := -1
+	flush := func(end int) {
+		if end > start {
+			tokens = append(tokens, text[start:end])
+		}
+		start, runes = end, 0
+	}
+
+	for i, r := range text {
+		class := charClass(r)
+		switch {
+		case class == classSpace && prevClass != classSpace:
+			// Whitespace starts a new token and is glued to the next word.
+			flush(i)
+		case class == classSpace:
+		case prevClass == classSpace && class == classWord:
+		case class != prevClass || class == classPunct || runes >= maxWordToken:
+			flush(i)
+		}
+		if class == classWord {
+			runes++
+		}
+		prevClass = class
+	}
+	flush(len(text))
+	return tokens
+}
+
+const (
+	classSpace = iota
+	classWord
+	classPunct
+)
+
+func charClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == utf8.RuneError:
+		return classWord
+	default:
+		return classPunct
+	}
+}
+
+// fitInput applies strategy to text so that every returned chunk has at most
+// maxTokens tokens. Only OverflowSplitAverage returns more than one chunk; the
+// weights are the token counts of the chunks. A non-positive maxTokens
+// disables the check.
+func fitInput(text string, maxTokens int, strategy OverflowStrategy, tok Tokenizer) ([]string, []int, error) {
+	if maxTokens <= 0 {
+		return []string{text}, []int{1}, nil
+	}
+	tokens := tok.Tokenize(text)
+	if len(tokens) <= maxTokens {
+		return []string{text}, []int{len(tokens)}, nil
+	}
+
+	switch strategy {
+	case OverflowTruncateTail:
+		return []string{join(tokens[:maxTokens])}, []int{maxTokens}, nil
+	case OverflowTruncateHead:
+		return []string{join(tokens[len(tokens)-maxTokens:])}, []int{maxTokens}, nil
+	case OverflowSplitAverage:
+		var (
+			chunks  []string
+			weights []int
+		)
+		for start := 0; start < len(tokens); start += maxTokens {
+			end := min(start+maxTokens, len(tokens))
+			chunks = append(chunks, join(tokens[start:end]))
+			weights = append(weights, end-start)
+		}
+		return chunks, weights, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %d tokens, limit %d", ErrInputTooLong, len(tokens), maxTokens)
+	}
+}
+
+func join(tokens []string) string {
+	n := 0
+	for _, t := range tokens {
+		n += len(t)
+	}
+	buf := make([]byte, 0, n)
+	for _, t := range tokens {
+		buf = append(buf, t...)
+	}
+	return string(buf)
+}
+
+// weightedAverage combines chunk vectors and L2-normalizes the result.
+func weightedAverage(vectors [][]float32, weights []int) []float32 {
+	if len(vectors) == 1 {
+		return vectors[0]
+	}
+	out := make([]float32, len(vectors[0]))
+	acc := make([]float64, len(out))
+	for i, vec := range vectors {
+		for j, v := range vec {
+			acc[j] += float64(v) * float64(weights[i])
+		}
+	}
+	var norm float64
+	for _, v := range acc {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return out
+	}
+	for j, v := range acc {
+		out[j] = float32(v / norm)
+	}
+	return out
+}
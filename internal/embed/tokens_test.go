@@ -0,0 +1,140 @@
+package embed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApproxTokenizer(t *testing.T) {
+	text := "func main() {\n\tfmt.Println(\"héllo, wörld\")\n}"
+	tokens := ApproxTokenizer{}.Tokenize(text)
+	if got := strings.Join(tokens, ""); got != text {
+		t.Errorf("tokens do not reassemble input: %q", got)
+	}
+	if len(tokens) < len(text)/4 {
+		t.Errorf("expected at least %d tokens, got %d", len(text)/4, len(tokens))
+	}
+}
+
+func TestFitInput(t *testing.T) {
+	text := "one two six ten ace"
+	tok := ApproxTokenizer{}
+
+	tests := []struct {
+		strategy OverflowStrategy
+		want     []string
+		weights  []int
+	}{
+		{OverflowTruncateTail, []string{"one two"}, []int{2}},
+		{OverflowTruncateHead, []string{" ten ace"}, []int{2}},
+		{OverflowSplitAverage, []string{"one two", " six ten", " ace"}, []int{2, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			chunks, weights, err := fitInput(text, 2, tt.strategy, tok)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(chunks, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("expected %q, got %q", tt.want, chunks)
+			}
+			for i := range weights {
+				if weights[i] != tt.weights[i] {
+					t.Errorf("expected weights %v, got %v", tt.weights, weights)
+					break
+				}
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		_, _, err := fitInput(text, 2, OverflowError, tok)
+		if !errors.Is(err, ErrInputTooLong) {
+			t.Errorf("expected ErrInputTooLong, got %v", err)
+		}
+	})
+
+	t.Run("fits", func(t *testing.T) {
+		chunks, _, err := fitInput(text, 100, OverflowError, tok)
+		if err != nil || len(chunks) != 1 || chunks[0] != text {
+			t.Errorf("expected input unchanged, got %q (%v)", chunks, err)
+		}
+	})
+}
+
+func TestOpenAIEmbedder_LongInput(t *testing.T) {
+	ctx := context.Background()
+
+	var inputs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		inputs = req.Input
+
+		data := make([]map[string]interface{}, len(req.Input))
+		for i := range req.Input {
+			vec := []float32{0, 0}
+			vec[i%2] = 1
+			data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": vec}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": data})
+	}))
+	defer srv.Close()
+
+	t.Run("SplitAverage", func(t *testing.T) {
+		e := NewOpenAIEmbedder("key", "test-model", WithBaseURL(srv.URL+"/v1"),
+			WithMaxTokens(2, OverflowSplitAverage), WithPreserveNewlines())
+
+		vec, err := e.Embed(ctx, "ab\ncd ef gh")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(inputs) != 2 || !strings.Contains(inputs[0], "\n") {
+			t.Errorf("expected two chunks with newline preserved, got %q", inputs)
+		}
+		if vec[0] <= 0 || vec[0] != vec[1] {
+			t.Errorf("expected equal-weight average of both chunks, got %v", vec)
+		}
+	})
+
+	t.Run("ErrorBeforeRequest", func(t *testing.T) {
+		inputs = nil
+		e := NewOpenAIEmbedder("key", "test-model", WithBaseURL(srv.URL+"/v1"), WithMaxTokens(2, OverflowError))
+		if _, err := e.Embed(ctx, "ab cd ef"); !errors.Is(err, ErrInputTooLong) {
+			t.Errorf("expected ErrInputTooLong, got %v", err)
+		}
+		if inputs != nil {
+			t.Error("expected no request to be sent")
+		}
+	})
+
+	t.Run("DefaultLimitLeftToAPI", func(t *testing.T) {
+		inputs = nil
+		e := NewOpenAIEmbedder("key", "text-embedding-ada-002", WithBaseURL(srv.URL+"/v1"))
+		long := strings.Repeat("ab ", 9000)
+		if _, err := e.Embed(ctx, long); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(inputs) != 1 {
+			t.Errorf("expected the input to be sent as is, got %d inputs", len(inputs))
+		}
+	})
+
+	t.Run("NewlinesReplacedByDefault", func(t *testing.T) {
+		e := NewOpenAIEmbedder("key", "test-model", WithBaseURL(srv.URL+"/v1"))
+		if _, err := e.Embed(ctx, "a\nb"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(inputs) != 1 || inputs[0] != "a b" {
+			t.Errorf("expected newline replaced, got %q", inputs)
+		}
+	})
+}
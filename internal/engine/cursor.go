@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// ErrInvalidCursor is returned when a search cursor is malformed or was
+// issued for a different query or different search options.
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
+// cursor is the decoded form of the opaque SearchPage.NextCursor token.
+type cursor struct {
+	Offset int `json:"o"`
+	// Skip lists the point IDs at or after Offset that diversified pages
+	// already showed or Filter rejected.
+	Skip []string `json:"k,omitempty"`
+	// Search is the searchHash of the search the cursor continues.
+	Search uint64 `json:"s"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor returns the position in the raw ranking encoded in token,
+// which must belong to search; an empty token starts at the beginning.
+func decodeCursor(token string, search uint64) (cursor, error) {
+	if token == "" {
+		return cursor{Search: search}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.Offset < 0 || c.Search != search {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// searchHash identifies a search by everything that shapes its raw ranking
+// or the way pages consume it, so that a cursor cannot continue a different
+// search. Limit is left out, as pages may differ in size, and so is Filter,
+// which cannot be compared; callers must keep it unchanged across pages.
+func (e *Engine) searchHash(query string, opts SearchOptions) uint64 {
+	var asOf int64
+	if !opts.AsOf.IsZero() {
+		asOf = opts.AsOf.UnixNano()
+	}
+	key := struct {
+		Query          string
+		Tenant         string
+		ScoreThreshold *float32
+		MMRLambda      *float64
+		MaxPerParent   int
+		ParentKey      string
+		HNSWEf         int
+		Exact          bool
+		Vectors        []string
+		AsOf           int64
+		FetchK         int
+	}{
+		query, e.tenant, opts.ScoreThreshold, opts.MMRLambda, opts.MaxPerParent, opts.ParentKey,
+		opts.HNSWEf, opts.Exact, opts.Vectors, asOf, opts.FetchK,
+	}
+	raw, _ := json.Marshal(key)
+	h := fnv.New64a()
+	h.Write(raw)
+	return h.Sum64()
+}
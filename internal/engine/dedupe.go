@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bondzai/grextor/internal/graph"
+	"github.com/bondzai/grextor/internal/vector"
+)
+
+// ContentHashKey is the payload key and node property holding the hash of
+// the embedded inputs of a document.
+const ContentHashKey = "content_hash"
+
+// DuplicateOfRel links a duplicate document to the stored document it
+// repeats.
+const DuplicateOfRel = "DUPLICATE_OF"
+
+// WithDeduplication makes ingestion detect documents that repeat a stored
+// document under another ID, such as a page republished under a new URL.
+// Such a duplicate gets a node linked to the original by a DUPLICATE_OF edge
+// and no vector of its own. Exact duplicates are found by content hash,
+// which needs a keyword payload index on content_hash. With nearThreshold
+// above zero, documents whose vector scores at least nearThreshold against a
+// stored document count as duplicates too.
+//
+// Duplicates are detected against stored documents only, so two copies
+// ingested in the same batch are both stored. A duplicate whose content
+// later changes is stored normally but keeps its DUPLICATE_OF edge. A stored
+// document re-ingested as a duplicate loses its point; with WithVersioning
+// the point is archived as a revision first.
+func WithDeduplication(nearThreshold float32) Option {
+	return func(e *Engine) {
+		e.dedupe = true
+		e.nearThreshold = nearThreshold
+	}
+}
+
+// contentHash identifies the embedded inputs of doc: its content, fields and
+// images. Metadata is not included, so a change to it alone does not cause
+// re-embedding. Every part is length-prefixed, so no content can spell out a
+// field.
+func contentHash(doc Document) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "content:%d:%s", len(doc.Content), doc.Content)
+
+	names := make([]string, 0, len(doc.Fields))
+	for name := range doc.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		text := doc.Fields[name]
+		fmt.Fprintf(h, "field:%d:%s:%d:%s", len(name), name, len(text), text)
+	}
+	for _, img := range doc.Images {
+		sum := sha256.Sum256(img.Data)
+		fmt.Fprintf(h, "image:%x", sum)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// storedPoint returns the stored point of document id, or nil if it is not
+// stored or the store cannot look it up. Vectors are loaded with
+// withVectors.
+func (e *Engine) storedPoint(ctx context.Context, id string, withVectors bool) (*vector.Point, error) {
+	lookup, ok := e.vectorStore.(vector.PointLookup)
+	if !ok {
+		return nil, nil
+	}
+	points, err := lookup.GetPoints(ctx, []string{id}, withVectors)
+	if err != nil {
+		return nil, fmt.Errorf("looking up stored document: %w", err)
+	}
+	for _, p := range points {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// findExactDuplicate returns the ID of a stored document of the tenant other
+// than id with the given content hash, or "".
+func (e *Engine) findExactDuplicate(ctx context.Context, id, hash string) (string, error) {
+	lookup, ok := e.vectorStore.(vector.PointLookup)
+	if !e.dedupe || !ok {
+		return "", nil
+	}
+	filter := e.tenantFilter(&vector.Filter{Must: []vector.Condition{{Key: ContentHashKey, Match: hash}}})
+	points, err := lookup.FindPoints(ctx, filter, 2)
+	if err != nil {
+		return "", fmt.Errorf("looking up duplicates: %w", err)
+	}
+	for _, p := range points {
+		// Superseded revisions are not originals.
+		if _, archived := p.Metadata[VersionOfKey]; p.ID != id && !archived {
+			return p.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// findNearDuplicate returns the ID of a stored document of the tenant other
+// than id whose vector scores at least the near-duplicate threshold against vec, or "".
+func (e *Engine) findNearDuplicate(ctx context.Context, id string, vec []float32) (string, error) {
+	if !e.dedupe || e.nearThreshold <= 0 {
+		return "", nil
+	}
+	threshold := e.nearThreshold
+	hits, err := e.vectorStore.SearchWithOptions(ctx, vec, vector.SearchOptions{
+		Limit:          2,
+		ScoreThreshold: &threshold,
+		Filter:         e.tenantFilter(versionFilter(time.Time{})),
+	})
+	if err != nil {
+		return "", fmt.Errorf("looking up near duplicates: %w", err)
+	}
+	for _, hit := range hits {
+		if hit.ID != id {
+			return hit.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// prepareDuplicate describes a duplicate of original: a node linked to it,
+// and no vectors. The point stored for the document, if any, is deleted, so
+// that searches no longer find its previous content.
+func (e *Engine) prepareDuplicate(id, original string, metadata map[string]interface{}, stored *vector.Point) *preparedDocument {
+	prepared := &preparedDocument{
+		node:  &graph.Node{ID: id, Label: "Document", Properties: metadata},
+		edges: []*graph.Edge{{FromID: id, ToID: original, Type: DuplicateOfRel}},
+	}
+	if stored != nil {
+		prepared.deleted = true
+		if e.versioned {
+			e.archiveVersion(prepared, stored, e.now().Unix())
+		}
+	}
+	return prepared
+}
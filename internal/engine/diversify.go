@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bondzai/grextor/internal/vector"
+)
+
+// diversifyPoints selects up to limit candidates, applying maximal marginal
+// relevance when lambda is set and capping hits per parent document when
+// maxPerParent is positive. Candidates are expected in descending score order.
+func diversifyPoints(candidates []*vector.ScoredPoint, limit int, lambda *float64, maxPerParent int, parentKey string) []*vector.ScoredPoint {
+	if limit <= 0 || limit > len(candidates) {
+		limit = len(candidates)
+	}
+
+	perParent := make(map[string]int)
+	allowed := func(sp *vector.ScoredPoint) bool {
+		if maxPerParent <= 0 {
+			return true
+		}
+		parent, ok := parentOf(sp, parentKey)
+		return !ok || perParent[parent] < maxPerParent
+	}
+	take := func(sp *vector.ScoredPoint) {
+		if parent, ok := parentOf(sp, parentKey); ok {
+			perParent[parent]++
+		}
+	}
+
+	selected := make([]*vector.ScoredPoint, 0, limit)
+
+	if lambda == nil {
+		for _, sp := range candidates {
+			if len(selected) == limit {
+				break
+			}
+			if allowed(sp) {
+				take(sp)
+				selected = append(selected, sp)
+			}
+		}
+		return selected
+	}
+
+	remaining := append([]*vector.ScoredPoint(nil), candidates...)
+	// maxSim[i] tracks the highest similarity of remaining[i] to any selected point.
+	maxSim := make([]float64, len(remaining))
+	for len(selected) < limit && len(remaining) > 0 {
+		best := -1
+		bestScore := math.Inf(-1)
+		for i, sp := range remaining {
+			if !allowed(sp) {
+				continue
+			}
+			score := *lambda*float64(sp.Score) - (1-*lambda)*maxSim[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		chosen := remaining[best]
+		take(chosen)
+		selected = append(selected, chosen)
+
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		maxSim = append(maxSim[:best], maxSim[best+1:]...)
+		for i, sp := range remaining {
+			if sim := cosineSimilarity(chosen.Vector, sp.Vector); sim > maxSim[i] {
+				maxSim[i] = sim
+			}
+		}
+	}
+	return selected
+}
+
+// parentOf returns the parent document identifier stored under key, if any.
+func parentOf(sp *vector.ScoredPoint, key string) (string, bool) {
+	v, ok := sp.Metadata[key]
+	if !ok || v == nil {
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+// cosineSimilarity returns 0 when either vector is missing or zero.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -2,8 +2,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/bondzai/grextor/internal/embed"
 	"github.com/bondzai/grextor/internal/graph"
@@ -14,55 +16,399 @@ type Engine struct {
 	embedder    embed.Embedder
 	vectorStore vector.Store
 	graphStore  graph.Store
+
+	imageEmbedder embed.ImageEmbedder
+	imageStore    vector.Store
+
+	// dedupe and nearThreshold configure WithDeduplication.
+	dedupe        bool
+	nearThreshold float32
+	// versioned enables WithVersioning; now dates revisions.
+	versioned bool
+	now       func() time.Time
+	// tenant scopes the engine, see WithTenant; tenantErr rejects an invalid
+	// tenant.
+	tenant    string
+	tenantErr error
+}
+
+// Option customises an Engine.
+type Option func(*Engine)
+
+// WithImages enables image ingestion: images are embedded with embedder and
+// stored in their own vector store, as they live in a different vector space
+// than text.
+func WithImages(embedder embed.ImageEmbedder, store vector.Store) Option {
+	return func(e *Engine) {
+		e.imageEmbedder = embedder
+		e.imageStore = store
+	}
 }
 
-func NewEngine(e embed.Embedder, v vector.Store, g graph.Store) *Engine {
-	return &Engine{
+func NewEngine(e embed.Embedder, v vector.Store, g graph.Store, opts ...Option) *Engine {
+	eng := &Engine{
 		embedder:    e,
 		vectorStore: v,
 		graphStore:  g,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(eng)
+	}
+	return eng
+}
+
+// Validate checks the tenant and that the embedder and vector store agree
+// on the vector size. Callers should refuse to start when it fails, as
+// writing vectors of the wrong size corrupts the collection.
+func (e *Engine) Validate(ctx context.Context) error {
+	if e.tenantErr != nil {
+		return e.tenantErr
 	}
+	dims, err := e.embedder.Dimensions(ctx)
+	if err != nil {
+		return fmt.Errorf("embedder dimensions: %w", err)
+	}
+	if checker, ok := e.vectorStore.(vector.DimensionChecker); ok {
+		if err := checker.CheckDimensions(ctx, uint64(dims)); err != nil {
+			return fmt.Errorf("vector store does not match embedder: %w", err)
+		}
+	}
+	if e.imageEmbedder == nil {
+		return nil
+	}
+	dims, err = e.imageEmbedder.Dimensions(ctx)
+	if err != nil {
+		return fmt.Errorf("image embedder dimensions: %w", err)
+	}
+	if checker, ok := e.imageStore.(vector.DimensionChecker); ok {
+		if err := checker.CheckDimensions(ctx, uint64(dims)); err != nil {
+			return fmt.Errorf("image store does not match image embedder: %w", err)
+		}
+	}
+	return nil
+}
+
+// FieldPayloadPrefix prefixes the payload keys holding the text of each named
+// field, so the named vectors can be recomputed from the payload.
+const FieldPayloadPrefix = "field_"
+
+// Document is a unit of ingestion. Content is embedded into the default
+// vector; each entry of Fields (e.g. "title" or "code") is embedded into the
+// named vector of the same name.
+type Document struct {
+	ID       string
+	Content  string
+	Fields   map[string]string
+	Metadata map[string]interface{}
+	// Images are embedded into the image store and linked to the document
+	// as Image nodes. They require WithImages.
+	Images []Image
 }
 
 // IngestDocument processes a document: embeds it, stores in vector DB, and creates a node in graph DB.
 func (e *Engine) IngestDocument(ctx context.Context, id, content string, metadata map[string]interface{}) error {
-	log.Printf("Ingesting document %s...", id)
+	return e.Ingest(ctx, Document{ID: id, Content: content, Metadata: metadata})
+}
+
+// Ingest embeds the content and fields of doc, stores the vectors and creates
+// the document node.
+func (e *Engine) Ingest(ctx context.Context, doc Document) error {
+	log.Printf("Ingesting document %s...", doc.ID)
 
-	// 1. Generate Embedding
-	vec, err := e.embedder.Embed(ctx, content)
+	prepared, err := e.prepare(ctx, doc)
 	if err != nil {
-		return fmt.Errorf("embedding failed: %w", err)
+		return err
+	}
+	if err := e.store(ctx, []*preparedDocument{prepared}); err != nil {
+		return err
 	}
 
-	// 2. Store in Vector DB
+	log.Printf("Successfully ingested document %s", doc.ID)
+	return nil
+}
+
+// IngestBatch ingests several documents with one vector upsert and one
+// graph transaction per label, which is much faster than calling Ingest for
+// each document. Nothing is stored if any document fails to embed, or if two
+// documents share an ID, as both would be compared with the same stored
+// revision.
+func (e *Engine) IngestBatch(ctx context.Context, docs []Document) error {
+	log.Printf("Ingesting %d documents...", len(docs))
+
+	ids := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		if ids[doc.ID] {
+			return fmt.Errorf("document %s appears more than once in the batch", doc.ID)
+		}
+		ids[doc.ID] = true
+	}
+
+	batch := make([]*preparedDocument, len(docs))
+	for i, doc := range docs {
+		prepared, err := e.prepare(ctx, doc)
+		if err != nil {
+			return fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		batch[i] = prepared
+	}
+	if err := e.store(ctx, batch); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully ingested %d documents", len(docs))
+	return nil
+}
+
+// Link adds edges between stored nodes in batches, e.g. the imports of a
+// dependency graph. With WithTenant both ends are documents of the tenant.
+// Edges with an end that is not stored are not created; the others are, and
+// a *graph.UnmatchedEdgesError lists the skipped edges by document ID.
+func (e *Engine) Link(ctx context.Context, edges []*graph.Edge) error {
+	if e.tenantErr != nil {
+		return e.tenantErr
+	}
+	scoped := make([]*graph.Edge, len(edges))
+	for i, edge := range edges {
+		scoped[i] = &graph.Edge{FromID: e.scope(edge.FromID), ToID: e.scope(edge.ToID), Type: edge.Type, Properties: edge.Properties}
+	}
+	err := e.graphStore.AddEdges(ctx, scoped)
+	var unmatched *graph.UnmatchedEdgesError
+	if errors.As(err, &unmatched) {
+		for i, edge := range unmatched.Edges {
+			unmatched.Edges[i] = &graph.Edge{FromID: e.unscope(edge.FromID), ToID: e.unscope(edge.ToID), Type: edge.Type, Properties: edge.Properties}
+		}
+		return unmatched
+	}
+	if err != nil {
+		return fmt.Errorf("graph storage failed: %w", err)
+	}
+	return nil
+}
+
+// preparedDocument holds the embedded form of a document, ready to store.
+type preparedDocument struct {
+	point *vector.Point
+	// unchanged marks a point whose stored vectors are current; only its
+	// payload is written.
+	unchanged bool
+	// deleted marks a stored document replaced by a duplicate, whose point
+	// is deleted.
+	deleted bool
+	node    *graph.Node
+	// archived is the revision replaced by a versioned document.
+	archived *vector.Point
+	// versions are the Version nodes of a versioned document.
+	versions []*graph.Node
+	// edges link the document to its versions, or to the document it
+	// duplicates.
+	edges  []*graph.Edge
+	images []*preparedImage
+}
+
+// prepare embeds the content, fields and images of doc. Embedding is
+// skipped when the store holds the document with the same content hash, or
+// with WithDeduplication, when it duplicates another document.
+func (e *Engine) prepare(ctx context.Context, doc Document) (*preparedDocument, error) {
+	if e.tenantErr != nil {
+		return nil, e.tenantErr
+	}
+	if err := checkDocumentID(doc.ID); err != nil {
+		return nil, err
+	}
+	if err := vector.CheckMetadata(doc.Metadata); err != nil {
+		return nil, err
+	}
+	if len(doc.Images) > 0 && e.imageEmbedder == nil {
+		return nil, fmt.Errorf("document %s has images but no image embedder is configured", doc.ID)
+	}
+
+	metadata := doc.Metadata
 	if metadata == nil {
 		metadata = make(map[string]interface{})
 	}
-	metadata["content"] = content // Store content in metadata for retrieval
+	metadata["content"] = doc.Content // Store content in metadata for retrieval
+	for name, text := range doc.Fields {
+		metadata[FieldPayloadPrefix+name] = text
+	}
+	hash := contentHash(doc)
+	metadata[ContentHashKey] = hash
+	if e.tenant != "" {
+		metadata[TenantKey] = e.tenant
+	}
+	id := e.scope(doc.ID)
 
-	err = e.vectorStore.Upsert(ctx, []*vector.Point{
-		{
+	// Skip documents that are already embedded, under this ID or another
+	stored, err := e.storedPoint(ctx, id, e.versioned)
+	if err != nil {
+		return nil, err
+	}
+	unchanged := stored != nil && stored.Metadata[ContentHashKey] == hash
+	if !unchanged {
+		original, err := e.findExactDuplicate(ctx, id, hash)
+		if err != nil {
+			return nil, err
+		}
+		if original != "" {
+			return e.prepareDuplicate(id, original, metadata, stored), nil
+		}
+	}
+
+	// 1. Generate Embeddings
+	var (
+		vec   []float32
+		named map[string][]float32
+	)
+	if !unchanged {
+		vec, err = e.embedder.Embed(ctx, doc.Content)
+		if err != nil {
+			return nil, fmt.Errorf("embedding failed: %w", err)
+		}
+		original, err := e.findNearDuplicate(ctx, id, vec)
+		if err != nil {
+			return nil, err
+		}
+		if original != "" {
+			return e.prepareDuplicate(id, original, metadata, stored), nil
+		}
+	}
+	if len(doc.Fields) > 0 && !unchanged {
+		named = make(map[string][]float32, len(doc.Fields))
+		for name, text := range doc.Fields {
+			fv, err := e.embedder.Embed(ctx, text)
+			if err != nil {
+				return nil, fmt.Errorf("embedding field %s failed: %w", name, err)
+			}
+			named[name] = fv
+		}
+	}
+
+	prepared := &preparedDocument{
+		unchanged: unchanged,
+		point: &vector.Point{
 			ID:       id,
 			Vector:   vec,
+			Vectors:  named,
 			Metadata: metadata,
 		},
-	})
-	if err != nil {
-		return fmt.Errorf("vector storage failed: %w", err)
+		node: &graph.Node{
+			ID:         id,
+			Label:      "Document",
+			Properties: metadata,
+		},
 	}
+	if unchanged {
+		keepVersion(metadata, stored.Metadata)
+	} else if e.versioned {
+		e.addVersion(prepared, stored)
+	}
+	for i, image := range doc.Images {
+		img, err := e.prepareImage(ctx, id, image, !unchanged)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+		prepared.images = append(prepared.images, img)
+	}
+	return prepared, nil
+}
 
-	// 3. Store in Graph DB (Node)
-	node := &graph.Node{
-		ID:         id,
-		Label:      "Document",
-		Properties: metadata,
+// store writes prepared documents: vectors first, then nodes and edges.
+func (e *Engine) store(ctx context.Context, batch []*preparedDocument) error {
+	if err := e.storeVectors(ctx, batch); err != nil {
+		return err
 	}
-	err = e.graphStore.AddNode(ctx, node)
-	if err != nil {
-		return fmt.Errorf("graph storage failed: %w", err)
+	return e.storeGraph(ctx, batch)
+}
+
+// storeVectors upserts the document and image points of a batch, updates
+// the payloads of unchanged documents and deletes the points of documents
+// that became duplicates.
+func (e *Engine) storeVectors(ctx context.Context, batch []*preparedDocument) error {
+	var (
+		points, payloads, imagePoints []*vector.Point
+		deleted                       []string
+	)
+	for _, doc := range batch {
+		if doc.deleted {
+			deleted = append(deleted, doc.node.ID)
+		}
+		switch {
+		case doc.point == nil:
+		case doc.unchanged:
+			payloads = append(payloads, doc.point)
+		default:
+			points = append(points, doc.point)
+		}
+		if doc.archived != nil {
+			points = append(points, doc.archived)
+		}
+		for _, img := range doc.images {
+			if img.point != nil {
+				imagePoints = append(imagePoints, img.point)
+			}
+		}
 	}
 
-	log.Printf("Successfully ingested document %s", id)
+	// 2. Store in Vector DB
+	if len(points) > 0 {
+		if err := e.vectorStore.Upsert(ctx, points); err != nil {
+			return fmt.Errorf("vector storage failed: %w", err)
+		}
+	}
+	if len(payloads) > 0 {
+		// Only stores implementing PointLookup report unchanged documents.
+		lookup, ok := e.vectorStore.(vector.PointLookup)
+		if !ok {
+			return fmt.Errorf("vector payload update failed: store cannot update payloads")
+		}
+		if err := lookup.UpdatePayloads(ctx, payloads); err != nil {
+			return fmt.Errorf("vector payload update failed: %w", err)
+		}
+	}
+	if len(deleted) > 0 {
+		// Only stores implementing PointLookup report stored documents.
+		lookup, ok := e.vectorStore.(vector.PointLookup)
+		if !ok {
+			return fmt.Errorf("vector deletion failed: store cannot delete points")
+		}
+		if err := lookup.DeletePoints(ctx, deleted); err != nil {
+			return fmt.Errorf("vector deletion failed: %w", err)
+		}
+	}
+	if len(imagePoints) > 0 {
+		if err := e.imageStore.Upsert(ctx, imagePoints); err != nil {
+			return fmt.Errorf("image vector storage failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// storeGraph writes the document and image nodes of a batch and the edges
+// between them.
+func (e *Engine) storeGraph(ctx context.Context, batch []*preparedDocument) error {
+	var (
+		nodes []*graph.Node
+		edges []*graph.Edge
+	)
+	for _, doc := range batch {
+		nodes = append(nodes, doc.node)
+		nodes = append(nodes, doc.versions...)
+		edges = append(edges, doc.edges...)
+		for _, img := range doc.images {
+			nodes = append(nodes, img.node)
+			edges = append(edges, img.edge)
+		}
+	}
+
+	// 3. Store in Graph DB (Nodes, then the edges between them)
+	if err := e.graphStore.AddNodes(ctx, nodes); err != nil {
+		return fmt.Errorf("graph storage failed: %w", err)
+	}
+	if len(edges) > 0 {
+		if err := e.graphStore.AddEdges(ctx, edges); err != nil {
+			return fmt.Errorf("graph storage failed: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -74,9 +420,103 @@ type SearchResult struct {
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
+// SearchOptions controls how Engine.SearchWithOptions ranks its results.
+type SearchOptions struct {
+	// Limit is the maximum number of results to return; it must be
+	// positive.
+	Limit int
+	// Cursor resumes a previous search from SearchPage.NextCursor. The
+	// query and other options must be the same as for the previous page,
+	// except Limit.
+	Cursor string
+	// ScoreThreshold drops hits scoring below the given value.
+	ScoreThreshold *float32
+	// Filter, when set, rejects hits after the vector search (for example
+	// hits that violate graph constraints). Rejected hits never shift the
+	// page boundaries of later cursors.
+	Filter func(SearchResult) bool
+	// MMRLambda enables maximal marginal relevance re-ranking when set.
+	// 1 ranks purely by relevance, 0 purely by diversity.
+	MMRLambda *float64
+	// MaxPerParent caps how many results may share the same parent document.
+	// Zero means no cap.
+	MaxPerParent int
+	// ParentKey is the metadata key identifying the parent document.
+	// Defaults to DefaultParentKey.
+	ParentKey string
+	// HNSWEf widens the HNSW search for this query to improve recall; zero
+	// uses the collection default.
+	HNSWEf int
+	// Exact scores every point instead of using the index, e.g. to measure
+	// the recall of the index.
+	Exact bool
+	// Vectors names the vectors to search in a multi-vector collection. Empty
+	// searches the default vector; several names are searched separately and
+	// fused with reciprocal rank fusion, which reaches the first 200 results.
+	Vectors []string
+	// AsOf searches the documents as they were at the given time, which
+	// needs WithVersioning. Zero searches the latest revisions.
+	AsOf time.Time
+	// FetchK is the number of candidates fetched before diversification.
+	// Defaults to four times Limit when MMR or a parent cap is enabled.
+	FetchK int
+}
+
+// SearchPage is one page of search results.
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	// NextCursor continues the search; empty when there are no more hits.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// DefaultParentKey is the metadata key used to group chunks of the same document.
+const DefaultParentKey = "parent_id"
+
+// maxFilterRounds bounds how many extra vector searches SearchPage issues
+// to refill a page after Filter rejected hits.
+const maxFilterRounds = 10
+
 func (e *Engine) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return e.SearchWithOptions(ctx, query, SearchOptions{Limit: limit})
+}
+
+// SearchWithOptions embeds the query, runs a vector search and optionally
+// diversifies the hits before mapping them to results.
+func (e *Engine) SearchWithOptions(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	page, err := e.SearchPage(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+// SearchPage is like SearchWithOptions but also returns a cursor for the next page.
+// Cursors address positions in the raw vector ranking, so hits removed by
+// Filter are skipped consistently across pages. Diversification is applied
+// within a page; candidates it passes over appear on later pages.
+func (e *Engine) SearchPage(ctx context.Context, query string, opts SearchOptions) (*SearchPage, error) {
 	log.Printf("Searching for: %s", query)
 
+	if e.tenantErr != nil {
+		return nil, e.tenantErr
+	}
+	if opts.Limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive, got %d", opts.Limit)
+	}
+	if opts.MMRLambda != nil && (*opts.MMRLambda < 0 || *opts.MMRLambda > 1) {
+		return nil, fmt.Errorf("mmr lambda must be between 0 and 1, got %v", *opts.MMRLambda)
+	}
+	if opts.HNSWEf < 0 {
+		return nil, fmt.Errorf("hnsw ef must not be negative, got %d", opts.HNSWEf)
+	}
+	diversify := opts.MMRLambda != nil || opts.MaxPerParent > 0
+
+	search := e.searchHash(query, opts)
+	pos, err := decodeCursor(opts.Cursor, search)
+	if err != nil {
+		return nil, err
+	}
+
 	// 1. Embed Query
 	vec, err := e.embedder.Embed(ctx, query)
 	if err != nil {
@@ -84,22 +524,134 @@ func (e *Engine) Search(ctx context.Context, query string, limit int) ([]SearchR
 	}
 
 	// 2. Vector Search
-	scoredPoints, err := e.vectorStore.Search(ctx, vec, limit)
+	vOpts := vector.SearchOptions{
+		Limit:          opts.Limit,
+		ScoreThreshold: opts.ScoreThreshold,
+		HNSWEf:         uint64(opts.HNSWEf),
+		Exact:          opts.Exact,
+		Filter:         e.tenantFilter(versionFilter(opts.AsOf)),
+	}
+	if diversify {
+		vOpts.Limit = opts.FetchK
+		if vOpts.Limit < opts.Limit {
+			vOpts.Limit = opts.Limit * 4
+		}
+		vOpts.WithVectors = opts.MMRLambda != nil
+	}
+
+	var (
+		accepted []*vector.ScoredPoint
+		next     = cursor{Offset: pos.Offset, Search: search}
+		more     bool
+	)
+	if diversify {
+		// 3. Diversify
+		accepted, next, more, err = e.diversifyPage(ctx, vec, vOpts, opts, pos)
+		if err != nil {
+			return nil, fmt.Errorf("vector search failed: %w", err)
+		}
+	}
+	for round := 0; round < maxFilterRounds && !diversify; round++ {
+		batchStart := next.Offset
+		vOpts.Offset = batchStart
+		scoredPoints, err := e.searchVectors(ctx, vec, vOpts, opts.Vectors)
+		if err != nil {
+			return nil, fmt.Errorf("vector search failed: %w", err)
+		}
+		more = len(scoredPoints) == vOpts.Limit
+
+		for _, sp := range scoredPoints {
+			next.Offset++
+			if opts.Filter == nil || opts.Filter(e.toSearchResult(sp)) {
+				accepted = append(accepted, sp)
+			}
+			if len(accepted) == opts.Limit {
+				more = more || next.Offset < batchStart+len(scoredPoints)
+				break
+			}
+		}
+		if len(accepted) >= opts.Limit || !more {
+			break
+		}
+	}
+
+	// 4. Map Results
+	page := &SearchPage{Results: make([]SearchResult, len(accepted))}
+	for i, sp := range accepted {
+		page.Results[i] = e.toSearchResult(sp)
+	}
+	if more {
+		page.NextCursor = encodeCursor(next)
+	}
+
+	return page, nil
+}
+
+// diversifyPage picks a diversified page from the candidate pool at pos.
+// Candidates that earlier pages showed are skipped, and the pool grows by as
+// many, so each page chooses among the same number of fresh candidates. The
+// cursor only advances past the leading candidates that were shown or
+// rejected by Filter; candidates passed over by diversification stay
+// available to later pages.
+func (e *Engine) diversifyPage(ctx context.Context, vec []float32, vOpts vector.SearchOptions, opts SearchOptions, pos cursor) ([]*vector.ScoredPoint, cursor, bool, error) {
+	vOpts.Offset = pos.Offset
+	vOpts.Limit += len(pos.Skip)
+	pool, err := e.searchVectors(ctx, vec, vOpts, opts.Vectors)
 	if err != nil {
-		return nil, fmt.Errorf("vector search failed: %w", err)
+		return nil, cursor{}, false, err
 	}
 
-	// 3. Map Results
-	results := make([]SearchResult, len(scoredPoints))
-	for i, sp := range scoredPoints {
-		content, _ := sp.Metadata["content"].(string)
-		results[i] = SearchResult{
-			ID:       sp.ID,
-			Score:    sp.Score,
-			Content:  content,
-			Metadata: sp.Metadata,
+	used := make(map[string]bool, len(pos.Skip)+opts.Limit)
+	for _, id := range pos.Skip {
+		used[id] = true
+	}
+	var candidates []*vector.ScoredPoint
+	for _, sp := range pool {
+		if used[sp.ID] {
+			continue
 		}
+		if opts.Filter != nil && !opts.Filter(e.toSearchResult(sp)) {
+			used[sp.ID] = true
+			continue
+		}
+		candidates = append(candidates, sp)
 	}
 
-	return results, nil
+	parentKey := opts.ParentKey
+	if parentKey == "" {
+		parentKey = DefaultParentKey
+	}
+	selected := diversifyPoints(candidates, opts.Limit, opts.MMRLambda, opts.MaxPerParent, parentKey)
+	for _, sp := range selected {
+		used[sp.ID] = true
+	}
+
+	consumed := 0
+	for consumed < len(pool) && used[pool[consumed].ID] {
+		consumed++
+	}
+	next := cursor{Offset: pos.Offset + consumed, Search: pos.Search}
+	for _, sp := range pool[consumed:] {
+		if used[sp.ID] {
+			next.Skip = append(next.Skip, sp.ID)
+		}
+	}
+	more := len(pool) == vOpts.Limit || len(next.Skip) < len(pool)-consumed
+	return selected, next, more, nil
+}
+
+// toSearchResult maps a hit to a result reporting the caller's document ID.
+func (e *Engine) toSearchResult(sp *vector.ScoredPoint) SearchResult {
+	content, _ := sp.Metadata["content"].(string)
+	id := e.unscope(sp.ID)
+	// An archived revision is reported under its document's ID.
+	if docID, ok := sp.Metadata[VersionOfKey].(string); ok {
+		id = docID
+	}
+	return SearchResult{
+		ID:       id,
+		Score:    sp.Score,
+		Content:  content,
+		Metadata: sp.Metadata,
+	}
 }
@@ -3,7 +3,12 @@ package engine
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bondzai/grextor/internal/graph"
 	"github.com/bondzai/grextor/internal/vector"
@@ -144,3 +149,1182 @@ func TestEngine_Search(t *testing.T) {
 		}
 	})
 }
+
+func TestEngine_SearchWithOptions(t *testing.T) {
+	ctx := context.Background()
+
+	// Two near-identical chunks of doc-a outrank a distinct chunk of doc-b.
+	candidates := []*vector.ScoredPoint{
+		{ID: "a1", Score: 0.95, Vector: []float32{1, 0}, Metadata: map[string]interface{}{"parent_id": "doc-a"}},
+		{ID: "a2", Score: 0.94, Vector: []float32{1, 0.01}, Metadata: map[string]interface{}{"parent_id": "doc-a"}},
+		{ID: "b1", Score: 0.80, Vector: []float32{0, 1}, Metadata: map[string]interface{}{"parent_id": "doc-b"}},
+	}
+
+	newEngine := func(t *testing.T, wantVectors bool) *Engine {
+		return NewEngine(&MockEmbedder{}, &MockVectorStore{
+			SearchWithOptionsFunc: func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+				if opts.WithVectors != wantVectors {
+					t.Errorf("expected WithVectors %v, got %v", wantVectors, opts.WithVectors)
+				}
+				if opts.Limit != 8 {
+					t.Errorf("expected candidate limit 8, got %d", opts.Limit)
+				}
+				return candidates, nil
+			},
+		}, &MockGraphStore{})
+	}
+
+	t.Run("MMR", func(t *testing.T) {
+		lambda := 0.5
+		results, err := newEngine(t, true).SearchWithOptions(ctx, "query", SearchOptions{Limit: 2, MMRLambda: &lambda})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != "a1" || results[1].ID != "b1" {
+			t.Errorf("expected [a1 b1], got %+v", results)
+		}
+	})
+
+	t.Run("MaxPerParent", func(t *testing.T) {
+		results, err := newEngine(t, false).SearchWithOptions(ctx, "query", SearchOptions{Limit: 2, MaxPerParent: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != "a1" || results[1].ID != "b1" {
+			t.Errorf("expected [a1 b1], got %+v", results)
+		}
+	})
+
+	t.Run("InvalidLambda", func(t *testing.T) {
+		lambda := 1.5
+		eng := NewEngine(&MockEmbedder{}, &MockVectorStore{}, &MockGraphStore{})
+		_, err := eng.SearchWithOptions(ctx, "query", SearchOptions{Limit: 2, MMRLambda: &lambda})
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestEngine_SearchPage(t *testing.T) {
+	ctx := context.Background()
+
+	ranked := make([]*vector.ScoredPoint, 6)
+	for i := range ranked {
+		ranked[i] = &vector.ScoredPoint{
+			ID:       string(rune('a' + i)),
+			Score:    1 - float32(i)/10,
+			Metadata: map[string]interface{}{"content": "doc"},
+		}
+	}
+	mockVectorStore := &MockVectorStore{
+		SearchWithOptionsFunc: func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+			var out []*vector.ScoredPoint
+			for _, sp := range ranked[min(opts.Offset, len(ranked)):] {
+				if opts.ScoreThreshold != nil && sp.Score < *opts.ScoreThreshold {
+					break
+				}
+				if len(out) == opts.Limit {
+					break
+				}
+				out = append(out, sp)
+			}
+			return out, nil
+		},
+	}
+	eng := NewEngine(&MockEmbedder{}, mockVectorStore, &MockGraphStore{})
+
+	collect := func(t *testing.T, opts SearchOptions) []string {
+		var ids []string
+		for page := 0; page < 10; page++ {
+			res, err := eng.SearchPage(ctx, "query", opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, r := range res.Results {
+				ids = append(ids, r.ID)
+			}
+			if res.NextCursor == "" {
+				return ids
+			}
+			opts.Cursor = res.NextCursor
+		}
+		t.Fatal("pagination did not terminate")
+		return nil
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		got := collect(t, SearchOptions{Limit: 4})
+		if want := "abcdef"; strings.Join(got, "") != want {
+			t.Errorf("expected %s, got %v", want, got)
+		}
+	})
+
+	t.Run("FilterKeepsPagesStable", func(t *testing.T) {
+		got := collect(t, SearchOptions{Limit: 2, Filter: func(r SearchResult) bool {
+			return r.ID != "b" && r.ID != "c"
+		}})
+		if want := "adef"; strings.Join(got, "") != want {
+			t.Errorf("expected %s, got %v", want, got)
+		}
+	})
+
+	t.Run("ScoreThreshold", func(t *testing.T) {
+		threshold := float32(0.75)
+		got := collect(t, SearchOptions{Limit: 2, ScoreThreshold: &threshold})
+		if want := "abc"; strings.Join(got, "") != want {
+			t.Errorf("expected %s, got %v", want, got)
+		}
+	})
+
+	t.Run("DiversifiedPagesKeepLeftovers", func(t *testing.T) {
+		for i, sp := range ranked {
+			sp.Metadata[DefaultParentKey] = fmt.Sprint(i / 2)
+		}
+		defer func() {
+			for _, sp := range ranked {
+				delete(sp.Metadata, DefaultParentKey)
+			}
+		}()
+		// One chunk per document and page: a, c and e, then b, d and f.
+		got := collect(t, SearchOptions{Limit: 2, MaxPerParent: 1})
+		if want := "acbdef"; strings.Join(got, "") != want {
+			t.Errorf("expected %s, got %v", want, got)
+		}
+		got = collect(t, SearchOptions{Limit: 2, MaxPerParent: 1, Filter: func(r SearchResult) bool {
+			return r.ID != "b"
+		}})
+		if want := "acdef"; strings.Join(got, "") != want {
+			t.Errorf("expected %s, got %v", want, got)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		if _, err := eng.SearchPage(ctx, "query", SearchOptions{}); err == nil {
+			t.Error("expected an error without a limit")
+		}
+	})
+
+	t.Run("CursorFromOtherQuery", func(t *testing.T) {
+		res, err := eng.SearchPage(ctx, "query", SearchOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, err = eng.SearchPage(ctx, "other", SearchOptions{Limit: 2, Cursor: res.NextCursor})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("CursorFromOtherOptions", func(t *testing.T) {
+		res, err := eng.SearchPage(ctx, "query", SearchOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		threshold := float32(0.75)
+		lambda := 0.5
+		for name, opts := range map[string]SearchOptions{
+			"ScoreThreshold": {ScoreThreshold: &threshold},
+			"MMR":            {MMRLambda: &lambda},
+			"MaxPerParent":   {MaxPerParent: 1},
+			"Vectors":        {Vectors: []string{"title"}},
+		} {
+			opts.Limit, opts.Cursor = 2, res.NextCursor
+			if _, err := eng.SearchPage(ctx, "query", opts); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+			}
+		}
+		if _, err := eng.SearchPage(ctx, "query", SearchOptions{Limit: 3, Cursor: res.NextCursor}); err != nil {
+			t.Errorf("expected a different page size to be accepted, got %v", err)
+		}
+	})
+}
+
+func TestEngine_Validate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Match", func(t *testing.T) {
+		store := &MockCheckedVectorStore{
+			CheckDimensionsFunc: func(ctx context.Context, size uint64) error {
+				if size != 3 {
+					t.Errorf("expected size 3, got %d", size)
+				}
+				return nil
+			},
+		}
+		if err := NewEngine(&MockEmbedder{}, store, &MockGraphStore{}).Validate(ctx); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		store := &MockCheckedVectorStore{
+			CheckDimensionsFunc: func(ctx context.Context, size uint64) error {
+				return &vector.MismatchError{Collection: "docs", Field: "size", Want: "3", Got: "1536"}
+			},
+		}
+		err := NewEngine(&MockEmbedder{}, store, &MockGraphStore{}).Validate(ctx)
+		var mismatch *vector.MismatchError
+		if !errors.As(err, &mismatch) {
+			t.Errorf("expected MismatchError, got %v", err)
+		}
+	})
+
+	t.Run("DimensionsError", func(t *testing.T) {
+		embedder := &MockEmbedder{
+			DimensionsFunc: func(ctx context.Context) (int, error) {
+				return 0, errors.New("probe failed")
+			},
+		}
+		if err := NewEngine(embedder, &MockVectorStore{}, &MockGraphStore{}).Validate(ctx); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestEngine_IngestFields(t *testing.T) {
+	ctx := context.Background()
+
+	var upserted *vector.Point
+	mockEmbedder := &MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{float32(len(text))}, nil
+		},
+	}
+	mockVectorStore := &MockVectorStore{
+		UpsertFunc: func(ctx context.Context, points []*vector.Point) error {
+			upserted = points[0]
+			return nil
+		},
+	}
+
+	eng := NewEngine(mockEmbedder, mockVectorStore, &MockGraphStore{})
+	err := eng.Ingest(ctx, Document{
+		ID:      "fn",
+		Content: "func Add(a, b int) int { return a + b }",
+		Fields:  map[string]string{"signature": "func Add(a, b int) int"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := upserted.Vector[0]; got != 39 {
+		t.Errorf("expected content vector [39], got %v", upserted.Vector)
+	}
+	if got := upserted.Vectors["signature"]; len(got) != 1 || got[0] != 22 {
+		t.Errorf("expected signature vector [22], got %v", got)
+	}
+	if got := upserted.Metadata[FieldPayloadPrefix+"signature"]; got != "func Add(a, b int) int" {
+		t.Errorf("expected field text in payload, got %v", got)
+	}
+}
+
+func TestEngine_SearchVectors(t *testing.T) {
+	ctx := context.Background()
+
+	rankings := map[string][]string{
+		"signature": {"a", "b", "c"},
+		"body":      {"b", "d", "a"},
+	}
+	var (
+		searched []string
+		limits   []int
+	)
+	mockVectorStore := &MockVectorStore{
+		SearchWithOptionsFunc: func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+			searched = append(searched, opts.VectorName)
+			limits = append(limits, opts.Limit)
+			if opts.Offset != 0 {
+				t.Errorf("expected per-vector searches from offset 0, got %d", opts.Offset)
+			}
+			var hits []*vector.ScoredPoint
+			for _, id := range rankings[opts.VectorName] {
+				if len(hits) == opts.Limit {
+					break
+				}
+				hits = append(hits, &vector.ScoredPoint{ID: id, Score: 0.9})
+			}
+			return hits, nil
+		},
+	}
+	eng := NewEngine(&MockEmbedder{}, mockVectorStore, &MockGraphStore{})
+
+	t.Run("Single", func(t *testing.T) {
+		searched = nil
+		results, err := eng.SearchWithOptions(ctx, "add", SearchOptions{Limit: 2, Vectors: []string{"body"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(searched) != 1 || searched[0] != "body" {
+			t.Errorf("expected one search of body, got %v", searched)
+		}
+		if ids := resultIDs(results); ids != "b,d" {
+			t.Errorf("expected b,d, got %s", ids)
+		}
+	})
+
+	t.Run("Fused", func(t *testing.T) {
+		searched, limits = nil, nil
+		page, err := eng.SearchPage(ctx, "add", SearchOptions{Limit: 2, Vectors: []string{"signature", "body"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(searched) != 2 {
+			t.Errorf("expected two searches, got %v", searched)
+		}
+		// b ranks 2nd and 1st, a ranks 1st and 3rd.
+		if ids := resultIDs(page.Results); ids != "b,a" {
+			t.Errorf("expected b,a, got %s", ids)
+		}
+
+		next, err := eng.SearchPage(ctx, "add", SearchOptions{Limit: 2, Vectors: []string{"signature", "body"}, Cursor: page.NextCursor})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ids := resultIDs(next.Results); ids != "d,c" {
+			t.Errorf("expected d,c on the second page, got %s", ids)
+		}
+		for _, l := range limits {
+			if l != limits[0] {
+				t.Errorf("expected the same per-vector depth on every page, got %v", limits)
+			}
+		}
+	})
+}
+
+func resultIDs(results []SearchResult) string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestEngine_IngestImages(t *testing.T) {
+	ctx := context.Background()
+	diagram := []byte("\x89PNG\r\n\x1a\ndiagram")
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			imagePoints []*vector.Point
+			nodes       []*graph.Node
+			edges       []*graph.Edge
+		)
+		imageStore := &MockVectorStore{
+			UpsertFunc: func(ctx context.Context, points []*vector.Point) error {
+				imagePoints = append(imagePoints, points...)
+				return nil
+			},
+		}
+		graphStore := &MockGraphStore{
+			AddNodeFunc: func(ctx context.Context, node *graph.Node) error {
+				nodes = append(nodes, node)
+				return nil
+			},
+			AddEdgeFunc: func(ctx context.Context, edge *graph.Edge) error {
+				edges = append(edges, edge)
+				return nil
+			},
+		}
+		eng := NewEngine(&MockEmbedder{}, &MockVectorStore{}, graphStore, WithImages(&MockImageEmbedder{}, imageStore))
+
+		for _, id := range []string{"doc-1", "doc-2"} {
+			images := []Image{{Data: diagram, Caption: "overview"}}
+			err := eng.Ingest(ctx, Document{ID: id, Content: "architecture", Images: images})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if images[0].ID != "" || images[0].MIMEType != "" {
+				t.Errorf("expected the caller's image to be left unchanged, got %+v", images[0])
+			}
+		}
+
+		if len(imagePoints) != 2 || imagePoints[0].ID != imagePoints[1].ID {
+			t.Fatalf("expected the same image ID for identical bytes, got %v", imagePoints)
+		}
+		if got := imagePoints[0].Metadata["mime_type"]; got != "image/png" {
+			t.Errorf("expected detected mime type image/png, got %v", got)
+		}
+		if nodes[1].Label != ImageLabel || nodes[1].ID != imagePoints[0].ID {
+			t.Errorf("expected an Image node for the image, got %+v", nodes[1])
+		}
+		if len(edges) != 2 || edges[1].FromID != "doc-2" || edges[1].Type != ReferencesImageRel {
+			t.Errorf("expected REFERENCES_IMAGE edges from each document, got %+v", edges)
+		}
+	})
+
+	t.Run("NoImageEmbedder", func(t *testing.T) {
+		eng := NewEngine(&MockEmbedder{}, &MockVectorStore{}, &MockGraphStore{})
+		err := eng.Ingest(ctx, Document{ID: "doc", Content: "x", Images: []Image{{Data: diagram}}})
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestEngine_SearchImages(t *testing.T) {
+	imageStore := &MockVectorStore{
+		SearchFunc: func(ctx context.Context, vec []float32, limit int) ([]*vector.ScoredPoint, error) {
+			return []*vector.ScoredPoint{{ID: "img", Score: 0.8, Metadata: map[string]interface{}{"content": "overview", "document_id": "doc-1"}}}, nil
+		},
+	}
+	eng := NewEngine(&MockEmbedder{}, &MockVectorStore{}, &MockGraphStore{}, WithImages(&MockImageEmbedder{}, imageStore))
+
+	results, err := eng.SearchImages(context.Background(), "system overview", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Content != "overview" || results[0].Metadata["document_id"] != "doc-1" {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestEngine_IngestBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		upserts   int
+		points    []*vector.Point
+		nodeCalls int
+		nodes     []*graph.Node
+	)
+	mockVectorStore := &MockVectorStore{
+		UpsertFunc: func(ctx context.Context, p []*vector.Point) error {
+			upserts++
+			points = append(points, p...)
+			return nil
+		},
+	}
+	mockGraphStore := &MockGraphStore{
+		AddNodesFunc: func(ctx context.Context, n []*graph.Node) error {
+			nodeCalls++
+			nodes = append(nodes, n...)
+			return nil
+		},
+		AddNodeFunc: func(ctx context.Context, node *graph.Node) error {
+			t.Error("expected batched node writes")
+			return nil
+		},
+	}
+	eng := NewEngine(&MockEmbedder{}, mockVectorStore, mockGraphStore)
+
+	docs := []Document{{ID: "a", Content: "one"}, {ID: "b", Content: "two"}, {ID: "c", Content: "three"}}
+	if err := eng.IngestBatch(ctx, docs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if upserts != 1 || len(points) != 3 {
+		t.Errorf("expected one upsert of 3 points, got %d upserts of %d points", upserts, len(points))
+	}
+	if nodeCalls != 1 || len(nodes) != 3 || nodes[2].ID != "c" {
+		t.Errorf("expected one AddNodes call with 3 nodes, got %d calls with %v", nodeCalls, nodes)
+	}
+
+	t.Run("EmbeddingError", func(t *testing.T) {
+		upserts = 0
+		failing := &MockEmbedder{
+			EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+				if text == "two" {
+					return nil, errors.New("embed error")
+				}
+				return []float32{1}, nil
+			},
+		}
+		eng := NewEngine(failing, mockVectorStore, mockGraphStore)
+		err := eng.IngestBatch(ctx, docs)
+		if err == nil || !strings.Contains(err.Error(), "document b") {
+			t.Errorf("expected error naming document b, got %v", err)
+		}
+		if upserts != 0 {
+			t.Errorf("expected nothing stored, got %d upserts", upserts)
+		}
+	})
+
+	t.Run("SameID", func(t *testing.T) {
+		upserts = 0
+		err := eng.IngestBatch(ctx, []Document{{ID: "a", Content: "one"}, {ID: "a", Content: "two"}})
+		if err == nil || !strings.Contains(err.Error(), "document a") {
+			t.Errorf("expected error naming document a, got %v", err)
+		}
+		if upserts != 0 {
+			t.Errorf("expected nothing stored, got %d upserts", upserts)
+		}
+	})
+}
+
+func TestEngine_Link(t *testing.T) {
+	var got []*graph.Edge
+	mockGraphStore := &MockGraphStore{
+		AddEdgesFunc: func(ctx context.Context, edges []*graph.Edge) error {
+			got = edges
+			return nil
+		},
+	}
+	eng := NewEngine(&MockEmbedder{}, &MockVectorStore{}, mockGraphStore)
+
+	edges := []*graph.Edge{{FromID: "a", ToID: "b", Type: "IMPORTS"}, {FromID: "b", ToID: "c", Type: "IMPORTS"}}
+	if err := eng.Link(context.Background(), edges); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected 2 edges in one call, got %v", got)
+	}
+}
+
+func TestEngine_IngestMany(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mu        sync.Mutex
+		upserted  = make(map[string]bool)
+		maxPoints int
+	)
+	mockVectorStore := &MockVectorStore{
+		UpsertFunc: func(ctx context.Context, points []*vector.Point) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, p := range points {
+				upserted[p.ID] = true
+			}
+			maxPoints = max(maxPoints, len(points))
+			return nil
+		},
+	}
+	mockGraphStore := &MockGraphStore{
+		AddNodesFunc: func(ctx context.Context, nodes []*graph.Node) error {
+			for _, n := range nodes {
+				if n.ID == "doc-42" {
+					return errors.New("graph error")
+				}
+			}
+			return nil
+		},
+	}
+	embedder := &MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			if text == "content 7" {
+				return nil, errors.New("embed error")
+			}
+			return []float32{1}, nil
+		},
+	}
+	eng := NewEngine(embedder, mockVectorStore, mockGraphStore)
+
+	docs := make(chan Document)
+	go func() {
+		defer close(docs)
+		for i := 0; i < 250; i++ {
+			docs <- Document{ID: fmt.Sprintf("doc-%d", i), Content: fmt.Sprintf("content %d", i)}
+		}
+	}()
+
+	results := make(map[string]error)
+	var progress []IngestStats
+	stats, err := eng.IngestMany(ctx, docs, IngestOptions{
+		EmbedWorkers: 8,
+		BatchSize:    10,
+		OnDocument:   func(id string, err error) { results[id] = err },
+		Progress:     func(s IngestStats) { progress = append(progress, s) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 250 {
+		t.Fatalf("expected an outcome for each of 250 documents, got %d", len(results))
+	}
+	if err := results["doc-7"]; err == nil || !strings.Contains(err.Error(), "embed error") {
+		t.Errorf("expected embedding error for doc-7, got %v", err)
+	}
+	if err := results["doc-42"]; err == nil || !strings.Contains(err.Error(), "graph error") {
+		t.Errorf("expected graph error for doc-42, got %v", err)
+	}
+	if upserted["doc-7"] {
+		t.Error("expected doc-7 not to be upserted")
+	}
+	if maxPoints > 10 {
+		t.Errorf("expected batches of at most 10, got %d", maxPoints)
+	}
+
+	// doc-7 fails alone, doc-42 fails with the rest of its batch.
+	if stats.Stored+stats.Failed != 250 || stats.Failed < 2 || stats.Failed > 11 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if last := progress[len(progress)-1]; last != stats {
+		t.Errorf("expected last progress %+v to equal stats %+v", last, stats)
+	}
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		docs := make(chan Document)
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case docs <- Document{ID: fmt.Sprint(i), Content: "x"}:
+				case <-ctx.Done():
+					return
+				}
+				if i == 20 {
+					cancel()
+				}
+			}
+		}()
+		_, err := eng.IngestMany(ctx, docs, IngestOptions{BatchSize: 5})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("SameID", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			stored   = make(map[string]*vector.Point)
+			embeds   int
+			payloads int
+		)
+		store := &MockLookupVectorStore{
+			MockVectorStore: MockVectorStore{
+				UpsertFunc: func(ctx context.Context, points []*vector.Point) error {
+					mu.Lock()
+					defer mu.Unlock()
+					for _, p := range points {
+						stored[p.ID] = p
+					}
+					return nil
+				},
+			},
+			GetPointsFunc: func(ctx context.Context, ids []string, withVectors bool) ([]*vector.Point, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if p, ok := stored[ids[0]]; ok {
+					return []*vector.Point{p}, nil
+				}
+				return nil, nil
+			},
+			UpdatePayloadsFunc: func(ctx context.Context, points []*vector.Point) error {
+				mu.Lock()
+				defer mu.Unlock()
+				payloads += len(points)
+				return nil
+			},
+		}
+		embedder := &MockEmbedder{
+			EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+				mu.Lock()
+				embeds++
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				return []float32{1}, nil
+			},
+		}
+		eng := NewEngine(embedder, store, &MockGraphStore{})
+
+		docs := make(chan Document, 3)
+		for i := 0; i < 3; i++ {
+			docs <- Document{ID: "a", Content: "same"}
+		}
+		close(docs)
+		// The batch never fills, so waiting documents must flush it.
+		stats, err := eng.IngestMany(ctx, docs, IngestOptions{EmbedWorkers: 3, BatchSize: 10})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stats.Stored != 3 {
+			t.Errorf("expected 3 stored documents, got %+v", stats)
+		}
+		if embeds != 1 || payloads != 2 {
+			t.Errorf("expected repeats to see the stored document, got %d embeds and %d payload updates", embeds, payloads)
+		}
+	})
+}
+
+func TestContentHash(t *testing.T) {
+	doc := Document{ID: "a", Content: "text", Fields: map[string]string{"title": "T", "code": "C"}}
+	hash := contentHash(doc)
+
+	same := Document{ID: "b", Content: "text", Fields: map[string]string{"code": "C", "title": "T"}, Metadata: map[string]interface{}{"url": "x"}}
+	if got := contentHash(same); got != hash {
+		t.Errorf("expected the hash to ignore ID, metadata and field order, got %s and %s", got, hash)
+	}
+
+	for name, other := range map[string]Document{
+		"Content": {Content: "other", Fields: doc.Fields},
+		"Field":   {Content: "text", Fields: map[string]string{"title": "T", "code": "D"}},
+		"Image":   {Content: "text", Fields: doc.Fields, Images: []Image{{Data: []byte("png")}}},
+		// A field must not be confused with content that spells it out.
+		"Boundary": {Content: "text" + "field:4:code:1:C", Fields: map[string]string{"title": "T"}},
+	} {
+		if contentHash(other) == hash {
+			t.Errorf("%s: expected a different hash", name)
+		}
+	}
+}
+
+func TestEngine_IngestUnchanged(t *testing.T) {
+	ctx := context.Background()
+	hash := contentHash(Document{Content: "same"})
+
+	var (
+		embeds   int
+		upserts  int
+		payloads []*vector.Point
+		nodes    []*graph.Node
+	)
+	embedder := &MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			embeds++
+			return []float32{1}, nil
+		},
+	}
+	store := &MockLookupVectorStore{
+		MockVectorStore: MockVectorStore{
+			UpsertFunc: func(ctx context.Context, points []*vector.Point) error {
+				upserts++
+				if h := points[0].Metadata[ContentHashKey]; h != contentHash(Document{Content: "changed"}) {
+					t.Errorf("expected the content hash in the payload, got %v", h)
+				}
+				return nil
+			},
+		},
+		GetPointsFunc: func(ctx context.Context, ids []string, withVectors bool) ([]*vector.Point, error) {
+			return []*vector.Point{{ID: ids[0], Metadata: map[string]interface{}{ContentHashKey: hash}}}, nil
+		},
+		UpdatePayloadsFunc: func(ctx context.Context, points []*vector.Point) error {
+			payloads = append(payloads, points...)
+			return nil
+		},
+	}
+	graphStore := &MockGraphStore{
+		AddNodeFunc: func(ctx context.Context, node *graph.Node) error {
+			nodes = append(nodes, node)
+			return nil
+		},
+	}
+	eng := NewEngine(embedder, store, graphStore)
+
+	meta := map[string]interface{}{"source": "new"}
+	if err := eng.Ingest(ctx, Document{ID: "a", Content: "same", Metadata: meta}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if embeds != 0 || upserts != 0 {
+		t.Errorf("expected no embedding or upsert for unchanged content, got %d embeds and %d upserts", embeds, upserts)
+	}
+	if len(payloads) != 1 || payloads[0].Metadata["source"] != "new" || payloads[0].Vector != nil {
+		t.Errorf("expected the payload alone to be updated, got %v", payloads)
+	}
+	if len(nodes) != 1 || nodes[0].Properties[ContentHashKey] != hash {
+		t.Errorf("expected the node to carry the content hash, got %v", nodes)
+	}
+
+	if err := eng.Ingest(ctx, Document{ID: "a", Content: "changed"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if embeds != 1 || upserts != 1 {
+		t.Errorf("expected changed content to be embedded and upserted, got %d embeds and %d upserts", embeds, upserts)
+	}
+
+	t.Run("ReservedMetadata", func(t *testing.T) {
+		doc := Document{ID: "a", Content: "same", Metadata: map[string]interface{}{vector.TimestampPathsKey: "mine"}}
+		if err := eng.Ingest(ctx, doc); err == nil {
+			t.Errorf("expected metadata key %s to be rejected", vector.TimestampPathsKey)
+		}
+	})
+
+	t.Run("NoLookup", func(t *testing.T) {
+		eng := NewEngine(embedder, &MockVectorStore{}, graphStore)
+		batch := []*preparedDocument{{unchanged: true, point: &vector.Point{ID: "a"}}}
+		if err := eng.storeVectors(ctx, batch); err == nil {
+			t.Error("expected an error from a store that cannot update payloads")
+		}
+	})
+}
+
+func TestEngine_Deduplication(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		upserts int
+		edges   []*graph.Edge
+	)
+	graphStore := &MockGraphStore{
+		AddEdgeFunc: func(ctx context.Context, edge *graph.Edge) error {
+			edges = append(edges, edge)
+			return nil
+		},
+	}
+	newStore := func() *MockLookupVectorStore {
+		upserts, edges = 0, nil
+		return &MockLookupVectorStore{
+			MockVectorStore: MockVectorStore{
+				UpsertFunc: func(ctx context.Context, points []*vector.Point) error {
+					upserts++
+					return nil
+				},
+			},
+		}
+	}
+	checkDuplicate := func(t *testing.T, original string) {
+		t.Helper()
+		if upserts != 0 {
+			t.Errorf("expected no vector for a duplicate, got %d upserts", upserts)
+		}
+		if len(edges) != 1 || edges[0].Type != DuplicateOfRel || edges[0].FromID != "new-url" || edges[0].ToID != original {
+			t.Errorf("expected a DUPLICATE_OF edge to %s, got %v", original, edges)
+		}
+	}
+
+	t.Run("Exact", func(t *testing.T) {
+		store := newStore()
+		store.FindPointsFunc = func(ctx context.Context, filter *vector.Filter, limit int) ([]*vector.Point, error) {
+			want := vector.Condition{Key: ContentHashKey, Match: contentHash(Document{Content: "page"})}
+			if len(filter.Must) != 1 || filter.Must[0] != want {
+				t.Errorf("unexpected lookup %v", filter)
+			}
+			return []*vector.Point{{ID: "old-url"}}, nil
+		}
+		embedder := &MockEmbedder{
+			EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+				t.Error("expected no embedding for an exact duplicate")
+				return nil, nil
+			},
+		}
+		eng := NewEngine(embedder, store, graphStore, WithDeduplication(0))
+		if err := eng.Ingest(ctx, Document{ID: "new-url", Content: "page"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkDuplicate(t, "old-url")
+	})
+
+	t.Run("Near", func(t *testing.T) {
+		store := newStore()
+		store.SearchWithOptionsFunc = func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+			if opts.ScoreThreshold == nil || *opts.ScoreThreshold != 0.95 {
+				t.Errorf("expected the near-duplicate threshold, got %v", opts.ScoreThreshold)
+			}
+			return []*vector.ScoredPoint{{ID: "new-url", Score: 1}, {ID: "similar", Score: 0.97}}, nil
+		}
+		eng := NewEngine(&MockEmbedder{}, store, graphStore, WithDeduplication(0.95))
+		if err := eng.Ingest(ctx, Document{ID: "new-url", Content: "page, lightly edited"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		checkDuplicate(t, "similar")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		store := newStore()
+		store.FindPointsFunc = func(ctx context.Context, filter *vector.Filter, limit int) ([]*vector.Point, error) {
+			t.Error("expected no duplicate lookup without WithDeduplication")
+			return nil, nil
+		}
+		eng := NewEngine(&MockEmbedder{}, store, graphStore)
+		if err := eng.Ingest(ctx, Document{ID: "new-url", Content: "page"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if upserts != 1 || len(edges) != 0 {
+			t.Errorf("expected a plain upsert, got %d upserts and edges %v", upserts, edges)
+		}
+	})
+
+	t.Run("Stored", func(t *testing.T) {
+		for _, versioned := range []bool{false, true} {
+			store := newStore()
+			var (
+				archived []*vector.Point
+				deleted  []string
+			)
+			store.UpsertFunc = func(ctx context.Context, points []*vector.Point) error {
+				archived = append(archived, points...)
+				return nil
+			}
+			store.GetPointsFunc = func(ctx context.Context, ids []string, withVectors bool) ([]*vector.Point, error) {
+				return []*vector.Point{{ID: "new-url", Vector: []float32{1}, Metadata: map[string]interface{}{
+					ContentHashKey: contentHash(Document{Content: "old page"}),
+					VersionKey:     int64(1),
+				}}}, nil
+			}
+			store.FindPointsFunc = func(ctx context.Context, filter *vector.Filter, limit int) ([]*vector.Point, error) {
+				return []*vector.Point{{ID: "old-url"}}, nil
+			}
+			store.DeletePointsFunc = func(ctx context.Context, ids []string) error {
+				deleted = append(deleted, ids...)
+				return nil
+			}
+			opts := []Option{WithDeduplication(0)}
+			if versioned {
+				opts = append(opts, WithVersioning())
+			}
+			eng := NewEngine(&MockEmbedder{}, store, graphStore, opts...)
+			if err := eng.Ingest(ctx, Document{ID: "new-url", Content: "page"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(deleted, []string{"new-url"}) {
+				t.Errorf("versioned=%v: expected the stale point to be deleted, got %v", versioned, deleted)
+			}
+			if !versioned {
+				if len(archived) != 0 {
+					t.Errorf("expected nothing to be upserted, got %v", archived)
+				}
+				continue
+			}
+			if len(archived) != 1 || archived[0].ID != "new-url@v1" || archived[0].Metadata[LatestKey] != false {
+				t.Errorf("expected the stale point to be archived, got %v", archived)
+			}
+			var types []string
+			for _, edge := range edges {
+				types = append(types, edge.Type)
+			}
+			if want := []string{DuplicateOfRel, HasVersionRel}; !reflect.DeepEqual(types, want) {
+				t.Errorf("edges = %v, want %v", types, want)
+			}
+		}
+	})
+}
+
+func TestEngine_Versioning(t *testing.T) {
+	ctx := context.Background()
+
+	points := make(map[string]*vector.Point)
+	store := &MockLookupVectorStore{
+		MockVectorStore: MockVectorStore{
+			UpsertFunc: func(ctx context.Context, p []*vector.Point) error {
+				for _, point := range p {
+					points[point.ID] = point
+				}
+				return nil
+			},
+		},
+		GetPointsFunc: func(ctx context.Context, ids []string, withVectors bool) ([]*vector.Point, error) {
+			if !withVectors {
+				t.Error("expected vectors to be loaded for archiving")
+			}
+			var found []*vector.Point
+			for _, id := range ids {
+				if p, ok := points[id]; ok {
+					found = append(found, p)
+				}
+			}
+			return found, nil
+		},
+		UpdatePayloadsFunc: func(ctx context.Context, p []*vector.Point) error {
+			for _, point := range p {
+				points[point.ID].Metadata = point.Metadata
+			}
+			return nil
+		},
+	}
+	nodes := make(map[string]*graph.Node)
+	var edges []string
+	graphStore := &MockGraphStore{
+		AddNodeFunc: func(ctx context.Context, node *graph.Node) error {
+			nodes[node.ID] = node
+			return nil
+		},
+		AddEdgeFunc: func(ctx context.Context, edge *graph.Edge) error {
+			edges = append(edges, fmt.Sprintf("%s-%s->%s", edge.FromID, edge.Type, edge.ToID))
+			return nil
+		},
+	}
+	embedder := &MockEmbedder{
+		EmbedFunc: func(ctx context.Context, text string) ([]float32, error) {
+			return []float32{float32(len(text))}, nil
+		},
+	}
+	eng := NewEngine(embedder, store, graphStore, WithVersioning())
+
+	t1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.AddDate(0, 3, 0)
+	eng.now = func() time.Time { return t1 }
+	if err := eng.Ingest(ctx, Document{ID: "policy", Content: "v1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eng.now = func() time.Time { return t2 }
+	if err := eng.Ingest(ctx, Document{ID: "policy", Content: "version 2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	current := points["policy"].Metadata
+	if current[VersionKey] != int64(2) || current[ValidFromKey] != t2.Unix() || current[LatestKey] != true {
+		t.Errorf("unexpected current revision %v", current)
+	}
+	archived, ok := points["policy@v1"]
+	if !ok {
+		t.Fatalf("expected the first revision to be archived, got points %v", points)
+	}
+	if archived.Vector[0] != 2 || archived.Metadata["content"] != "v1" {
+		t.Errorf("expected the archive to keep the first revision and its vector, got %v", archived)
+	}
+	am := archived.Metadata
+	if am[VersionOfKey] != "policy" || am[ValidFromKey] != t1.Unix() || am[ValidToKey] != t2.Unix() || am[LatestKey] != false {
+		t.Errorf("unexpected archived revision %v", am)
+	}
+
+	for _, id := range []string{"policy@v1", "policy@v2"} {
+		if n, ok := nodes[id]; !ok || n.Label != VersionLabel {
+			t.Errorf("expected Version node %s, got %v", id, n)
+		}
+	}
+	wantEdges := []string{
+		"policy-HAS_VERSION->policy@v1",
+		"policy@v2-PREVIOUS_VERSION->policy@v1",
+		"policy-HAS_VERSION->policy@v2",
+	}
+	for _, want := range wantEdges {
+		found := false
+		for _, e := range edges {
+			found = found || e == want
+		}
+		if !found {
+			t.Errorf("expected edge %s, got %v", want, edges)
+		}
+	}
+
+	// An unchanged re-ingest keeps the revision.
+	if err := eng.Ingest(ctx, Document{ID: "policy", Content: "version 2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if current := points["policy"].Metadata; current[VersionKey] != int64(2) || current[ValidFromKey] != t2.Unix() {
+		t.Errorf("expected the revision to be kept, got %v", current)
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		var filter *vector.Filter
+		store.SearchWithOptionsFunc = func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+			filter = opts.Filter
+			return []*vector.ScoredPoint{{ID: "policy@v1", Metadata: archived.Metadata}}, nil
+		}
+
+		if _, err := eng.Search(ctx, "policy", 5); err != nil {
+			t.Fatal(err)
+		}
+		if len(filter.MustNot) != 1 || filter.MustNot[0].Key != LatestKey || filter.MustNot[0].Match != false {
+			t.Errorf("expected superseded revisions to be excluded, got %+v", filter)
+		}
+
+		asOf := t1.AddDate(0, 1, 0)
+		results, err := eng.SearchWithOptions(ctx, "policy", SearchOptions{Limit: 5, AsOf: asOf})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Points without validity keys, stored before versioning, are kept.
+		if len(filter.MustNot) != 2 || filter.MustNot[0].Key != ValidFromKey || *filter.MustNot[0].Range.GT != float64(asOf.Unix()) ||
+			filter.MustNot[1].Key != ValidToKey || *filter.MustNot[1].Range.LTE != float64(asOf.Unix()) {
+			t.Errorf("expected revisions outside %v to be excluded, got %+v", asOf, filter)
+		}
+		if len(results) != 1 || results[0].ID != "policy" || results[0].Content != "v1" {
+			t.Errorf("expected the archived revision under the document ID, got %v", results)
+		}
+	})
+
+	t.Run("RevisionLikeID", func(t *testing.T) {
+		if err := eng.Ingest(ctx, Document{ID: "policy@v1", Content: "mine"}); err == nil {
+			t.Error("expected an ID ending like a revision ID to be rejected")
+		}
+		if err := eng.Ingest(ctx, Document{ID: "user@example.com", Content: "mine"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestEngine_Tenants(t *testing.T) {
+	ctx := context.Background()
+
+	points := make(map[string]*vector.Point)
+	store := &MockVectorStore{
+		UpsertFunc: func(ctx context.Context, p []*vector.Point) error {
+			for _, point := range p {
+				points[point.ID] = point
+			}
+			return nil
+		},
+	}
+	nodes := make(map[string]*graph.Node)
+	var edges []*graph.Edge
+	graphStore := &MockGraphStore{
+		AddNodeFunc: func(ctx context.Context, node *graph.Node) error {
+			nodes[node.ID] = node
+			return nil
+		},
+		AddEdgesFunc: func(ctx context.Context, e []*graph.Edge) error {
+			edges = append(edges, e...)
+			return nil
+		},
+	}
+	acme := NewEngine(&MockEmbedder{}, store, graphStore, WithTenant("acme"))
+	globex, err := acme.ForTenant("globex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acme.Tenant() != "acme" || globex.Tenant() != "globex" {
+		t.Fatalf("unexpected tenants %q and %q", acme.Tenant(), globex.Tenant())
+	}
+
+	for _, eng := range []*Engine{acme, globex} {
+		if err := eng.Ingest(ctx, Document{ID: "readme", Content: eng.Tenant() + " readme"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	for _, tenant := range []string{"acme", "globex"} {
+		p, ok := points[tenant+":readme"]
+		if !ok || p.Metadata[TenantKey] != tenant || p.Metadata["content"] != tenant+" readme" {
+			t.Errorf("expected point %s:readme of tenant %s, got %v", tenant, tenant, p)
+		}
+		if n, ok := nodes[tenant+":readme"]; !ok || n.Properties[TenantKey] != tenant {
+			t.Errorf("expected node %s:readme of tenant %s, got %v", tenant, tenant, n)
+		}
+	}
+
+	if err := acme.Link(ctx, []*graph.Edge{{FromID: "readme", ToID: "install", Type: "LINKS_TO"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(edges) != 1 || edges[0].FromID != "acme:readme" || edges[0].ToID != "acme:install" {
+		t.Errorf("expected the edge to stay within the tenant, got %v", edges)
+	}
+
+	graphStore.AddEdgesFunc = func(ctx context.Context, e []*graph.Edge) error {
+		return &graph.UnmatchedEdgesError{Edges: e}
+	}
+	err = acme.Link(ctx, []*graph.Edge{{FromID: "readme", ToID: "missing", Type: "LINKS_TO"}})
+	var unmatched *graph.UnmatchedEdgesError
+	if !errors.As(err, &unmatched) || len(unmatched.Edges) != 1 ||
+		unmatched.Edges[0].FromID != "readme" || unmatched.Edges[0].ToID != "missing" {
+		t.Errorf("expected the edge not created to be reported by document ID, got %v", err)
+	}
+
+	var filter *vector.Filter
+	store.SearchWithOptionsFunc = func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+		filter = opts.Filter
+		return []*vector.ScoredPoint{{ID: "acme:readme", Metadata: points["acme:readme"].Metadata}}, nil
+	}
+	results, err := acme.Search(ctx, "readme", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := vector.Condition{Key: TenantKey, Match: "acme"}
+	if len(filter.Must) != 1 || filter.Must[0] != want || len(filter.MustNot) != 1 {
+		t.Errorf("expected the latest revisions of acme only, got %+v", filter)
+	}
+	if len(results) != 1 || results[0].ID != "readme" {
+		t.Errorf("expected the result under its document ID, got %v", results)
+	}
+
+	// Tenant "a:b" would share the store ID a:b:x with document b:x of
+	// tenant "a".
+	if _, err := acme.ForTenant("a:b"); err == nil {
+		t.Error("expected ForTenant to reject an invalid tenant")
+	}
+	colliding := NewEngine(&MockEmbedder{}, store, graphStore, WithTenant("a:b"))
+	if err := colliding.Validate(ctx); err == nil {
+		t.Error("expected Validate to reject an invalid tenant")
+	}
+	if err := colliding.Ingest(ctx, Document{ID: "x", Content: "x"}); err == nil {
+		t.Error("expected Ingest to reject an invalid tenant")
+	}
+	if _, err := colliding.Search(ctx, "x", 5); err == nil {
+		t.Error("expected Search to reject an invalid tenant")
+	}
+	if _, ok := points["a:b:x"]; ok {
+		t.Error("expected nothing to be stored for an invalid tenant")
+	}
+	if err := NewEngine(&MockEmbedder{}, store, graphStore).Validate(ctx); err != nil {
+		t.Errorf("expected no tenant to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"sort"
+
+	"github.com/bondzai/grextor/internal/vector"
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion; 60 is the
+// value from the original paper and works well without tuning.
+const rrfK = 60
+
+// fusionDepth is the number of hits fetched per vector before fusion. It
+// does not depend on the page, so every page slices the same fused ranking;
+// fused searches end after fusionDepth results.
+const fusionDepth = 200
+
+// searchVectors runs one vector search against the named vectors in names.
+// With several names each vector is searched separately and the rankings are
+// merged with reciprocal rank fusion, so opts.Offset and opts.Limit address
+// the fused ranking of the top fusionDepth hits of each vector and scores
+// are fusion scores.
+func (e *Engine) searchVectors(ctx context.Context, vec []float32, opts vector.SearchOptions, names []string) ([]*vector.ScoredPoint, error) {
+	if len(names) <= 1 {
+		if len(names) == 1 {
+			opts.VectorName = names[0]
+		}
+		return e.vectorStore.SearchWithOptions(ctx, vec, opts)
+	}
+
+	perVector := opts
+	perVector.Offset = 0
+	perVector.Limit = fusionDepth
+	rankings := make([][]*vector.ScoredPoint, len(names))
+	for i, name := range names {
+		perVector.VectorName = name
+		hits, err := e.vectorStore.SearchWithOptions(ctx, vec, perVector)
+		if err != nil {
+			return nil, err
+		}
+		rankings[i] = hits
+	}
+
+	fused := fuseRankings(rankings)
+	if opts.Offset >= len(fused) {
+		return nil, nil
+	}
+	fused = fused[opts.Offset:]
+	if len(fused) > opts.Limit {
+		fused = fused[:opts.Limit]
+	}
+	return fused, nil
+}
+
+// fuseRankings merges rankings with reciprocal rank fusion. Points keep the
+// metadata and vector of their first occurrence.
+func fuseRankings(rankings [][]*vector.ScoredPoint) []*vector.ScoredPoint {
+	scores := make(map[string]float64)
+	var order []*vector.ScoredPoint
+	for _, ranking := range rankings {
+		for rank, sp := range ranking {
+			if _, seen := scores[sp.ID]; !seen {
+				order = append(order, sp)
+			}
+			scores[sp.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	fused := make([]*vector.ScoredPoint, len(order))
+	for i, sp := range order {
+		p := *sp
+		p.Score = float32(scores[sp.ID])
+		fused[i] = &p
+	}
+	sort.SliceStable(fused, func(i, j int) bool { return fused[i].Score > fused[j].Score })
+	return fused
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bondzai/grextor/internal/embed"
+	"github.com/bondzai/grextor/internal/graph"
+	"github.com/bondzai/grextor/internal/vector"
+	"github.com/google/uuid"
+)
+
+// Graph vocabulary for images.
+const (
+	ImageLabel         = "Image"
+	ReferencesImageRel = "REFERENCES_IMAGE"
+)
+
+// Image is a non-text payload referenced by a document, such as an
+// architecture diagram.
+type Image struct {
+	// ID defaults to a UUID derived from Data, so an image referenced by
+	// several documents is stored once.
+	ID       string
+	Data     []byte
+	MIMEType string
+	// Source records where the image came from, e.g. a path or URL.
+	Source string
+	// Caption is optional alt text; it is returned as the content of image
+	// search results.
+	Caption string
+}
+
+// preparedImage holds an embedded image and its graph entries.
+type preparedImage struct {
+	point *vector.Point
+	node  *graph.Node
+	edge  *graph.Edge
+}
+
+// prepareImage embeds img and describes its point, node and link to the
+// document stored as docID. Without embed the point is left out, as the
+// image is stored already. img is a copy, so defaults filled in here do not
+// change the caller's document.
+func (e *Engine) prepareImage(ctx context.Context, docID string, img Image, embed bool) (*preparedImage, error) {
+	if len(img.Data) == 0 {
+		return nil, errors.New("empty image")
+	}
+	if img.MIMEType == "" {
+		img.MIMEType = http.DetectContentType(img.Data)
+	}
+	sum := sha256.Sum256(img.Data)
+	if img.ID == "" {
+		img.ID = uuid.NewSHA1(uuid.NameSpaceOID, sum[:]).String()
+	}
+
+	props := map[string]interface{}{
+		"mime_type": img.MIMEType,
+		"sha256":    hex.EncodeToString(sum[:]),
+		"size":      len(img.Data),
+	}
+	if img.Source != "" {
+		props["source"] = img.Source
+	}
+	if img.Caption != "" {
+		props["caption"] = img.Caption
+	}
+	if e.tenant != "" {
+		props[TenantKey] = e.tenant
+	}
+
+	metadata := make(map[string]interface{}, len(props)+2)
+	for k, v := range props {
+		metadata[k] = v
+	}
+	metadata["content"] = img.Caption
+	metadata["document_id"] = e.unscope(docID)
+
+	// Images are shared by the documents of a tenant only.
+	id := e.scope(img.ID)
+	prepared := &preparedImage{
+		node: &graph.Node{ID: id, Label: ImageLabel, Properties: props},
+		edge: &graph.Edge{FromID: docID, ToID: id, Type: ReferencesImageRel},
+	}
+	if !embed {
+		return prepared, nil
+	}
+	vec, err := e.imageEmbedder.EmbedImage(ctx, img.Data, img.MIMEType)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	prepared.point = &vector.Point{ID: id, Vector: vec, Metadata: metadata}
+	return prepared, nil
+}
+
+// SearchImages finds images matching a text query. It requires an image
+// embedder that also embeds text into the image space, as CLIP models do.
+// Results carry the caption as content and the most recent referencing document in
+// metadata["document_id"].
+func (e *Engine) SearchImages(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	if e.tenantErr != nil {
+		return nil, e.tenantErr
+	}
+	textEmbedder, ok := e.imageEmbedder.(embed.Embedder)
+	if !ok {
+		return nil, errors.New("image search needs an image embedder that embeds text")
+	}
+
+	vec, err := textEmbedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query embedding failed: %w", err)
+	}
+	points, err := e.imageStore.SearchWithOptions(ctx, vec, vector.SearchOptions{Limit: limit, Filter: e.tenantFilter(nil)})
+	if err != nil {
+		return nil, fmt.Errorf("image search failed: %w", err)
+	}
+
+	results := make([]SearchResult, len(points))
+	for i, sp := range points {
+		results[i] = e.toSearchResult(sp)
+	}
+	return results, nil
+}
@@ -9,7 +9,8 @@ import (
 
 // MockEmbedder implements embed.Embedder
 type MockEmbedder struct {
-	EmbedFunc func(ctx context.Context, text string) ([]float32, error)
+	EmbedFunc      func(ctx context.Context, text string) ([]float32, error)
+	DimensionsFunc func(ctx context.Context) (int, error)
 }
 
 func (m *MockEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
@@ -19,10 +20,19 @@ func (m *MockEmbedder) Embed(ctx context.Context, text string) ([]float32, error
 	return []float32{0.1, 0.2, 0.3}, nil
 }
 
+func (m *MockEmbedder) Dimensions(ctx context.Context) (int, error) {
+	if m.DimensionsFunc != nil {
+		return m.DimensionsFunc(ctx)
+	}
+	return 3, nil
+}
+
 // MockVectorStore implements vector.Store
 type MockVectorStore struct {
 	UpsertFunc func(ctx context.Context, points []*vector.Point) error
 	SearchFunc func(ctx context.Context, vec []float32, limit int) ([]*vector.ScoredPoint, error)
+
+	SearchWithOptionsFunc func(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error)
 }
 
 func (m *MockVectorStore) Upsert(ctx context.Context, points []*vector.Point) error {
@@ -39,10 +49,20 @@ func (m *MockVectorStore) Search(ctx context.Context, vec []float32, limit int)
 	return nil, nil
 }
 
+func (m *MockVectorStore) SearchWithOptions(ctx context.Context, vec []float32, opts vector.SearchOptions) ([]*vector.ScoredPoint, error) {
+	if m.SearchWithOptionsFunc != nil {
+		return m.SearchWithOptionsFunc(ctx, vec, opts)
+	}
+	return m.Search(ctx, vec, opts.Limit)
+}
+
 // MockGraphStore implements graph.Store
 type MockGraphStore struct {
 	AddNodeFunc func(ctx context.Context, node *graph.Node) error
 	AddEdgeFunc func(ctx context.Context, edge *graph.Edge) error
+
+	AddNodesFunc func(ctx context.Context, nodes []*graph.Node) error
+	AddEdgesFunc func(ctx context.Context, edges []*graph.Edge) error
 }
 
 func (m *MockGraphStore) AddNode(ctx context.Context, node *graph.Node) error {
@@ -58,3 +78,90 @@ func (m *MockGraphStore) AddEdge(ctx context.Context, edge *graph.Edge) error {
 	}
 	return nil
 }
+
+func (m *MockGraphStore) AddNodes(ctx context.Context, nodes []*graph.Node) error {
+	if m.AddNodesFunc != nil {
+		return m.AddNodesFunc(ctx, nodes)
+	}
+	for _, node := range nodes {
+		if err := m.AddNode(ctx, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockGraphStore) AddEdges(ctx context.Context, edges []*graph.Edge) error {
+	if m.AddEdgesFunc != nil {
+		return m.AddEdgesFunc(ctx, edges)
+	}
+	for _, edge := range edges {
+		if err := m.AddEdge(ctx, edge); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MockCheckedVectorStore is a MockVectorStore implementing vector.DimensionChecker
+type MockCheckedVectorStore struct {
+	MockVectorStore
+	CheckDimensionsFunc func(ctx context.Context, size uint64) error
+}
+
+func (m *MockCheckedVectorStore) CheckDimensions(ctx context.Context, size uint64) error {
+	if m.CheckDimensionsFunc != nil {
+		return m.CheckDimensionsFunc(ctx, size)
+	}
+	return nil
+}
+
+// MockImageEmbedder implements embed.ImageEmbedder and embed.Embedder
+type MockImageEmbedder struct {
+	MockEmbedder
+	EmbedImageFunc func(ctx context.Context, data []byte, mimeType string) ([]float32, error)
+}
+
+func (m *MockImageEmbedder) EmbedImage(ctx context.Context, data []byte, mimeType string) ([]float32, error) {
+	if m.EmbedImageFunc != nil {
+		return m.EmbedImageFunc(ctx, data, mimeType)
+	}
+	return []float32{0.3, 0.2, 0.1}, nil
+}
+
+// MockLookupVectorStore is a MockVectorStore implementing vector.PointLookup
+type MockLookupVectorStore struct {
+	MockVectorStore
+	GetPointsFunc      func(ctx context.Context, ids []string, withVectors bool) ([]*vector.Point, error)
+	FindPointsFunc     func(ctx context.Context, filter *vector.Filter, limit int) ([]*vector.Point, error)
+	UpdatePayloadsFunc func(ctx context.Context, points []*vector.Point) error
+	DeletePointsFunc   func(ctx context.Context, ids []string) error
+}
+
+func (m *MockLookupVectorStore) GetPoints(ctx context.Context, ids []string, withVectors bool) ([]*vector.Point, error) {
+	if m.GetPointsFunc != nil {
+		return m.GetPointsFunc(ctx, ids, withVectors)
+	}
+	return nil, nil
+}
+
+func (m *MockLookupVectorStore) FindPoints(ctx context.Context, filter *vector.Filter, limit int) ([]*vector.Point, error) {
+	if m.FindPointsFunc != nil {
+		return m.FindPointsFunc(ctx, filter, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockLookupVectorStore) UpdatePayloads(ctx context.Context, points []*vector.Point) error {
+	if m.UpdatePayloadsFunc != nil {
+		return m.UpdatePayloadsFunc(ctx, points)
+	}
+	return nil
+}
+
+func (m *MockLookupVectorStore) DeletePoints(ctx context.Context, ids []string) error {
+	if m.DeletePointsFunc != nil {
+		return m.DeletePointsFunc(ctx, ids)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package engine
+
+import (
+	"context"
+	"sync"
+)
+
+// IngestOptions sizes the stages of IngestMany. Zero values use the
+// defaults below.
+type IngestOptions struct {
+	// EmbedWorkers embed documents concurrently. Defaults to 4.
+	EmbedWorkers int
+	// VectorWorkers upsert batches into the vector store concurrently.
+	// Defaults to 2.
+	VectorWorkers int
+	// GraphWorkers write batches to the graph store concurrently. Defaults
+	// to 2.
+	GraphWorkers int
+	// BatchSize is the number of documents per store write. A partial batch
+	// is written once the input channel is closed. Defaults to 100.
+	BatchSize int
+	// QueueSize bounds the embedded documents waiting to be batched. When
+	// the stores fall behind, the queues fill up and the embed workers stop
+	// reading input. Defaults to twice BatchSize.
+	QueueSize int
+
+	// OnDocument is called once per document: with a nil error once it is
+	// stored, or with the error that stopped it. A failed store write fails
+	// every document of its batch.
+	OnDocument func(id string, err error)
+	// Progress is called with the running totals whenever they change.
+	Progress func(IngestStats)
+}
+
+// IngestStats counts the documents processed by IngestMany.
+type IngestStats struct {
+	Stored int
+	Failed int
+}
+
+func (o IngestOptions) withDefaults() IngestOptions {
+	if o.EmbedWorkers <= 0 {
+		o.EmbedWorkers = 4
+	}
+	if o.VectorWorkers <= 0 {
+		o.VectorWorkers = 2
+	}
+	if o.GraphWorkers <= 0 {
+		o.GraphWorkers = 2
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 2 * o.BatchSize
+	}
+	return o
+}
+
+// IngestMany ingests the documents received from docs until it is closed,
+// through a pipeline of embed, vector-write and graph-write worker pools
+// joined by bounded queues. A failing document is reported through
+// OnDocument and does not stop the others. Documents are stored out of
+// order, except that a document is only embedded once the previous one with
+// its ID has been stored or has failed, as it is compared with the stored
+// revision.
+//
+// When ctx is cancelled IngestMany stops reading docs, drops the documents
+// in flight without reporting them and returns ctx.Err(); producers should
+// select on ctx when sending. The callbacks are never called concurrently.
+func (e *Engine) IngestMany(ctx context.Context, docs <-chan Document, opts IngestOptions) (IngestStats, error) {
+	opts = opts.withDefaults()
+	var (
+		prepared = make(chan *preparedDocument, opts.QueueSize)
+		toVector = make(chan []*preparedDocument, opts.VectorWorkers)
+		toGraph  = make(chan []*preparedDocument, opts.GraphWorkers)
+		done     = make(chan struct{})
+		r        = &ingestReporter{opts: opts}
+		locks    = &idLocks{held: make(map[string]chan struct{}), waited: make(map[string]bool)}
+		// flush asks the batcher to write its partial batch, which may hold
+		// a document whose ID another document waits for.
+		flush = make(chan struct{}, 1)
+	)
+
+	// 1. Embed
+	startWorkers(opts.EmbedWorkers, prepared, func() {
+		for {
+			doc, ok := receive(ctx, docs)
+			if !ok {
+				return
+			}
+			if !locks.acquire(ctx, doc.ID, func() {
+				select {
+				case flush <- struct{}{}:
+				default:
+				}
+			}) {
+				return
+			}
+			p, err := e.prepare(ctx, doc)
+			if err != nil {
+				locks.release(doc.ID)
+				if ctx.Err() == nil {
+					r.report(err, doc.ID)
+				}
+				continue
+			}
+			if !send(ctx, prepared, p) {
+				return
+			}
+		}
+	})
+
+	// Batch
+	startWorkers(1, toVector, func() {
+		batch := make([]*preparedDocument, 0, opts.BatchSize)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case p, ok := <-prepared:
+				if !ok {
+					if len(batch) > 0 {
+						send(ctx, toVector, batch)
+					}
+					return
+				}
+				batch = append(batch, p)
+				if len(batch) < opts.BatchSize && !locks.isWaited(e.unscope(p.node.ID)) {
+					continue
+				}
+			case <-flush:
+				if len(batch) == 0 {
+					continue
+				}
+			}
+			if !send(ctx, toVector, batch) {
+				return
+			}
+			batch = make([]*preparedDocument, 0, opts.BatchSize)
+		}
+	})
+
+	// 2. Store vectors
+	startWorkers(opts.VectorWorkers, toGraph, func() {
+		for {
+			batch, ok := receive(ctx, toVector)
+			if !ok {
+				return
+			}
+			if err := e.storeVectors(ctx, batch); err != nil {
+				ids := e.batchIDs(batch)
+				locks.release(ids...)
+				if ctx.Err() == nil {
+					r.report(err, ids...)
+				}
+				continue
+			}
+			if !send(ctx, toGraph, batch) {
+				return
+			}
+		}
+	})
+
+	// 3. Store in the graph
+	startWorkers(opts.GraphWorkers, done, func() {
+		for {
+			batch, ok := receive(ctx, toGraph)
+			if !ok {
+				return
+			}
+			err := e.storeGraph(ctx, batch)
+			ids := e.batchIDs(batch)
+			locks.release(ids...)
+			if ctx.Err() == nil {
+				r.report(err, ids...)
+			}
+		}
+	})
+
+	<-done
+	return r.totals(), ctx.Err()
+}
+
+// startWorkers runs fn in n goroutines and closes out once all of them
+// return, which tells the next stage that no more input is coming.
+func startWorkers[T any](n int, out chan T, fn func()) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+}
+
+// receive reads from ch; ok is false once ch is closed or ctx is done.
+func receive[T any](ctx context.Context, ch <-chan T) (v T, ok bool) {
+	select {
+	case <-ctx.Done():
+		return v, false
+	case v, ok = <-ch:
+		return v, ok
+	}
+}
+
+// send writes v to ch; it returns false if ctx is done first.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case ch <- v:
+		return true
+	}
+}
+
+// batchIDs returns the document IDs of a batch as the caller knows them.
+func (e *Engine) batchIDs(batch []*preparedDocument) []string {
+	ids := make([]string, len(batch))
+	for i, p := range batch {
+		ids[i] = e.unscope(p.node.ID)
+	}
+	return ids
+}
+
+// idLocks lets one document per ID through the pipeline at a time.
+type idLocks struct {
+	mu   sync.Mutex
+	held map[string]chan struct{}
+	// waited marks held IDs that another document waits for.
+	waited map[string]bool
+}
+
+// acquire takes id, waiting until the document holding it releases it. wait
+// is called before waiting. It returns false if ctx is done first.
+func (l *idLocks) acquire(ctx context.Context, id string, wait func()) bool {
+	for {
+		l.mu.Lock()
+		released, held := l.held[id]
+		if !held {
+			l.held[id] = make(chan struct{})
+			l.mu.Unlock()
+			return true
+		}
+		l.waited[id] = true
+		l.mu.Unlock()
+
+		wait()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-released:
+		}
+	}
+}
+
+// isWaited reports whether a document waits for id.
+func (l *idLocks) isWaited(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waited[id]
+}
+
+func (l *idLocks) release(ids ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if released, ok := l.held[id]; ok {
+			close(released)
+			delete(l.held, id)
+			delete(l.waited, id)
+		}
+	}
+}
+
+// ingestReporter counts outcomes and serialises the callbacks of
+// IngestOptions.
+type ingestReporter struct {
+	opts IngestOptions
+
+	mu    sync.Mutex
+	stats IngestStats
+}
+
+// report records the outcome of the documents ids: stored if err is nil,
+// failed otherwise.
+func (r *ingestReporter) report(err error, ids ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		if err != nil {
+			r.stats.Failed++
+		} else {
+			r.stats.Stored++
+		}
+		if r.opts.OnDocument != nil {
+			r.opts.OnDocument(id, err)
+		}
+	}
+	if r.opts.Progress != nil {
+		r.opts.Progress(r.stats)
+	}
+}
+
+func (r *ingestReporter) totals() IngestStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
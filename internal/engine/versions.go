@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/bondzai/grextor/internal/graph"
+	"github.com/bondzai/grextor/internal/vector"
+)
+
+// Graph vocabulary for versioned documents.
+const (
+	VersionLabel       = "Version"
+	HasVersionRel      = "HAS_VERSION"
+	PreviousVersionRel = "PREVIOUS_VERSION"
+)
+
+// Payload keys and node properties of versioned documents. Times are Unix
+// seconds.
+const (
+	VersionKey = "version"
+	// VersionOfKey holds the document ID of an archived revision.
+	VersionOfKey = "version_of"
+	ValidFromKey = "valid_from"
+	ValidToKey   = "valid_to"
+	LatestKey    = "latest"
+)
+
+// openEnded is the valid_to of a current revision: 9999-12-31T23:59:59Z.
+const openEnded int64 = 253402300799
+
+// versionKeys describe the revision of a stored document.
+var versionKeys = []string{VersionKey, ValidFromKey, ValidToKey, LatestKey}
+
+// WithVersioning keeps the history of documents instead of overwriting it.
+// When the content of a document changes, the revision it replaces is
+// archived as a point of its own, "<id>@v<n>", valid until then. Every
+// revision also gets a Version node, linked from the document by HAS_VERSION
+// and to the revision it replaced by PREVIOUS_VERSION. Searches see the
+// latest revisions unless SearchOptions.AsOf asks for an earlier time.
+//
+// Versioning needs a vector store implementing vector.PointLookup.
+// Documents stored before versioning was enabled have no validity keys, so
+// AsOf searches treat them as valid at any time until they change.
+func WithVersioning() Option {
+	return func(e *Engine) { e.versioned = true }
+}
+
+// versionID is the point and node ID of a revision.
+func versionID(docID string, version int64) string {
+	return fmt.Sprintf("%s@v%d", docID, version)
+}
+
+var revisionSuffix = regexp.MustCompile(`@v[0-9]+$`)
+
+// checkDocumentID rejects IDs ending like a revision ID, "@v<n>", which
+// would collide with a revision of another document.
+func checkDocumentID(id string) error {
+	if revisionSuffix.MatchString(id) {
+		return fmt.Errorf("document ID %q is reserved: IDs ending in @v<n> name revisions", id)
+	}
+	return nil
+}
+
+// keepVersion carries the revision of a stored point over to the metadata
+// of an unchanged re-ingest, whose payload replaces the stored one.
+func keepVersion(metadata, stored map[string]interface{}) {
+	for _, key := range versionKeys {
+		if v, ok := stored[key]; ok {
+			metadata[key] = v
+		}
+	}
+}
+
+// addVersion makes prepared the next revision of stored, which is nil for a
+// new document, and archives stored.
+func (e *Engine) addVersion(prepared *preparedDocument, stored *vector.Point) {
+	var (
+		now      = e.now().Unix()
+		id       = prepared.node.ID
+		metadata = prepared.point.Metadata
+		version  = int64(1)
+	)
+	if stored != nil {
+		prev := e.archiveVersion(prepared, stored, now)
+		version = prev + 1
+		prepared.edges = append(prepared.edges,
+			&graph.Edge{FromID: versionID(id, version), ToID: versionID(id, prev), Type: PreviousVersionRel})
+	}
+
+	metadata[VersionKey] = version
+	metadata[ValidFromKey] = now
+	metadata[ValidToKey] = openEnded
+	metadata[LatestKey] = true
+
+	props := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		props[k] = v
+	}
+	props[VersionOfKey] = e.unscope(id)
+	current := &graph.Node{ID: versionID(id, version), Label: VersionLabel, Properties: props}
+	prepared.versions = append(prepared.versions, current)
+	prepared.edges = append(prepared.edges, &graph.Edge{FromID: id, ToID: current.ID, Type: HasVersionRel})
+}
+
+// archiveVersion archives stored, the revision of prepared valid until now,
+// and returns its version.
+func (e *Engine) archiveVersion(prepared *preparedDocument, stored *vector.Point, now int64) int64 {
+	id := prepared.node.ID
+	// A document stored before versioning was enabled is its first revision.
+	prev, ok := int64Value(stored.Metadata[VersionKey])
+	if !ok {
+		prev = 1
+	}
+
+	archived := make(map[string]interface{}, len(stored.Metadata)+4)
+	for k, v := range stored.Metadata {
+		archived[k] = v
+	}
+	archived[VersionKey] = prev
+	archived[VersionOfKey] = e.unscope(id)
+	archived[ValidToKey] = now
+	archived[LatestKey] = false
+
+	prevID := versionID(id, prev)
+	prepared.archived = &vector.Point{ID: prevID, Vector: stored.Vector, Vectors: stored.Vectors, Metadata: archived}
+	prepared.versions = append(prepared.versions, &graph.Node{ID: prevID, Label: VersionLabel, Properties: archived})
+	prepared.edges = append(prepared.edges, &graph.Edge{FromID: id, ToID: prevID, Type: HasVersionRel})
+	return prev
+}
+
+// versionFilter selects the revisions valid at asOf, or the latest ones if
+// asOf is zero. Points without revision keys count as latest and as valid
+// at any time, so the filter only excludes points outside asOf.
+func versionFilter(asOf time.Time) *vector.Filter {
+	if asOf.IsZero() {
+		return &vector.Filter{MustNot: []vector.Condition{{Key: LatestKey, Match: false}}}
+	}
+	t := float64(asOf.Unix())
+	return &vector.Filter{MustNot: []vector.Condition{
+		{Key: ValidFromKey, Range: &vector.Range{GT: &t}},
+		{Key: ValidToKey, Range: &vector.Range{LTE: &t}},
+	}}
+}
+
+// int64Value converts a numeric payload value.
+func int64Value(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
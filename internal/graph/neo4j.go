@@ -2,22 +2,154 @@ package graph
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
 )
 
 type Neo4jStore struct {
 	driver neo4j.DriverWithContext
+	// database is the target database; empty uses the server default.
+	database string
+	// txTimeout bounds each transaction on the server; zero uses the server
+	// default.
+	txTimeout time.Duration
+	// batchSize caps the rows written per transaction by AddNodes/AddEdges.
+	batchSize int
 }
 
-func NewNeo4jStore(uri, username, password string) (*Neo4jStore, error) {
-	driver, err := neo4j.NewDriverWithContext(uri, neo4j.BasicAuth(username, password, ""))
+// Neo4jOption customises a Neo4jStore.
+type Neo4jOption func(*neo4jOptions)
+
+type neo4jOptions struct {
+	database     string
+	bearerToken  string
+	realm        string
+	caFile       string
+	maxPoolSize  int
+	maxRetryTime time.Duration
+	txTimeout    time.Duration
+	batchSize    int
+}
+
+// WithDatabase sends all queries to the named database, e.g. a per-team
+// database on a shared instance.
+func WithDatabase(name string) Neo4jOption {
+	return func(o *neo4jOptions) { o.database = name }
+}
+
+// WithBearerAuth authenticates with an SSO token instead of the username and
+// password.
+func WithBearerAuth(token string) Neo4jOption {
+	return func(o *neo4jOptions) { o.bearerToken = token }
+}
+
+// WithRealm sets the realm of basic authentication, for servers with
+// several auth providers.
+func WithRealm(realm string) Neo4jOption {
+	return func(o *neo4jOptions) { o.realm = realm }
+}
+
+// WithCAFile trusts the CAs in a PEM file in addition to the system roots
+// for neo4j+s:// and bolt+s:// URIs.
+func WithCAFile(path string) Neo4jOption {
+	return func(o *neo4jOptions) { o.caFile = path }
+}
+
+// WithMaxPoolSize caps the number of connections kept per server.
+func WithMaxPoolSize(n int) Neo4jOption {
+	return func(o *neo4jOptions) { o.maxPoolSize = n }
+}
+
+// WithMaxTransactionRetryTime bounds how long transient failures, such as
+// leader switches, are retried.
+func WithMaxTransactionRetryTime(d time.Duration) Neo4jOption {
+	return func(o *neo4jOptions) { o.maxRetryTime = d }
+}
+
+// WithQueryTimeout makes the server abort transactions running longer
+// than d.
+func WithQueryTimeout(d time.Duration) Neo4jOption {
+	return func(o *neo4jOptions) { o.txTimeout = d }
+}
+
+// WithBatchSize caps the rows AddNodes and AddEdges write per transaction.
+// It defaults to DefaultBatchSize.
+func WithBatchSize(n int) Neo4jOption {
+	return func(o *neo4jOptions) { o.batchSize = n }
+}
+
+// DefaultBatchSize is the number of rows written per transaction by default.
+const DefaultBatchSize = 1000
+
+func NewNeo4jStore(uri, username, password string, opts ...Neo4jOption) (*Neo4jStore, error) {
+	var o neo4jOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = DefaultBatchSize
+	}
+
+	auth := neo4j.BasicAuth(username, password, o.realm)
+	if o.bearerToken != "" {
+		auth = neo4j.BearerAuth(o.bearerToken)
+	}
+
+	var tlsConfig *tls.Config
+	if o.caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.caFile)
+		}
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	}
+
+	driver, err := neo4j.NewDriverWithContext(uri, auth, func(c *config.Config) {
+		if tlsConfig != nil {
+			c.TlsConfig = tlsConfig
+		}
+		if o.maxPoolSize > 0 {
+			c.MaxConnectionPoolSize = o.maxPoolSize
+		}
+		if o.maxRetryTime > 0 {
+			c.MaxTransactionRetryTime = o.maxRetryTime
+		}
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create driver: %w", err)
 	}
 
-	return &Neo4jStore{driver: driver}, nil
+	return &Neo4jStore{driver: driver, database: o.database, txTimeout: o.txTimeout, batchSize: o.batchSize}, nil
+}
+
+// writeSession opens a write session on the configured database.
+func (s *Neo4jStore) writeSession(ctx context.Context) neo4j.SessionWithContext {
+	return s.driver.NewSession(ctx, neo4j.SessionConfig{
+		AccessMode:   neo4j.AccessModeWrite,
+		DatabaseName: s.database,
+	})
+}
+
+// txConfig returns the per-transaction settings.
+func (s *Neo4jStore) txConfig() []func(*neo4j.TransactionConfig) {
+	if s.txTimeout <= 0 {
+		return nil
+	}
+	return []func(*neo4j.TransactionConfig){neo4j.WithTxTimeout(s.txTimeout)}
 }
 
 func (s *Neo4jStore) Close(ctx context.Context) error {
@@ -29,51 +161,249 @@ func (s *Neo4jStore) VerifyConnectivity(ctx context.Context) error {
 }
 
 func (s *Neo4jStore) AddNode(ctx context.Context, node *Node) error {
-	session := s.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close(ctx)
+	return s.AddNodes(ctx, []*Node{node})
+}
+
+func (s *Neo4jStore) AddEdge(ctx context.Context, edge *Edge) error {
+	return s.AddEdges(ctx, []*Edge{edge})
+}
 
-	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		query := fmt.Sprintf("MERGE (n:%s {id: $id}) SET n += $props", node.Label)
-		params := map[string]interface{}{
+// AddNodes merges nodes in batches of the configured size. Each batch is
+// written in one transaction with one UNWIND query per label.
+func (s *Neo4jStore) AddNodes(ctx context.Context, nodes []*Node) error {
+	groups := make(map[string][]map[string]interface{})
+	var labels []string
+	for _, node := range nodes {
+		if _, ok := groups[node.Label]; !ok {
+			labels = append(labels, node.Label)
+		}
+		groups[node.Label] = append(groups[node.Label], map[string]interface{}{
 			"id":    node.ID,
-			"props": node.Properties,
+			"props": neo4jProperties(node.Properties),
+		})
+	}
+
+	queries := make([]batchQuery, len(labels))
+	for i, label := range labels {
+		queries[i] = batchQuery{cypher: nodeQuery(label), rows: groups[label]}
+	}
+	if _, err := s.writeBatches(ctx, queries); err != nil {
+		return fmt.Errorf("failed to add nodes: %w", err)
+	}
+	return nil
+}
+
+// AddEdges merges edges in batches of the configured size. Each batch is
+// written in one transaction with one UNWIND query per relationship type.
+// Edges with a missing end are reported by an *UnmatchedEdgesError.
+func (s *Neo4jStore) AddEdges(ctx context.Context, edges []*Edge) error {
+	groups := make(map[string][]map[string]interface{})
+	var types []string
+	for _, edge := range edges {
+		if _, ok := groups[edge.Type]; !ok {
+			types = append(types, edge.Type)
 		}
-		_, err := tx.Run(ctx, query, params)
-		return nil, err
-	})
+		groups[edge.Type] = append(groups[edge.Type], map[string]interface{}{
+			"from":  edge.FromID,
+			"to":    edge.ToID,
+			"props": neo4jProperties(edge.Properties),
+		})
+	}
 
+	queries := make([]batchQuery, len(types))
+	for i, typ := range types {
+		queries[i] = batchQuery{
+			cypher:    edgeQuery(typ),
+			check:     crossTenantQuery(),
+			unmatched: unmatchedEdgesQuery(),
+			relType:   typ,
+			rows:      groups[typ],
+		}
+	}
+	unmatched, err := s.writeBatches(ctx, queries)
 	if err != nil {
-		return fmt.Errorf("failed to add node: %w", err)
+		return fmt.Errorf("failed to add edges: %w", err)
+	}
+	if len(unmatched) > 0 {
+		return &UnmatchedEdgesError{Edges: unmatched}
 	}
 	return nil
 }
 
-func (s *Neo4jStore) AddEdge(ctx context.Context, edge *Edge) error {
-	session := s.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+// nodeQuery merges rows of {id, props} into nodes with the given label. The
+// merge goes through EntityLabel so it is backed by the uniqueness
+// constraint of EnsureSchema.
+func nodeQuery(label string) string {
+	return fmt.Sprintf("UNWIND $rows AS row MERGE (n:%s {id: row.id}) SET n:%s, n += row.props",
+		quoteIdentifier(EntityLabel), quoteIdentifier(label))
+}
+
+// edgeQuery merges rows of {from, to, props} into relationships of the given
+// type, looking up both ends by id through EntityLabel.
+func edgeQuery(typ string) string {
+	entity := quoteIdentifier(EntityLabel)
+	return fmt.Sprintf(`
+		UNWIND $rows AS row
+		MATCH (a:%s {id: row.from})
+		MATCH (b:%s {id: row.to})
+		MERGE (a)-[r:%s]->(b)
+		SET r += row.props
+	`, entity, entity, quoteIdentifier(typ))
+}
+
+// crossTenantQuery returns the first of rows of {from, to} whose ends belong
+// to different tenants. Nodes without a tenant belong to the "" tenant.
+func crossTenantQuery() string {
+	entity := quoteIdentifier(EntityLabel)
+	return fmt.Sprintf(`
+		UNWIND $rows AS row
+		MATCH (a:%[1]s {id: row.from})
+		MATCH (b:%[1]s {id: row.to})
+		WHERE coalesce(a.%[2]s, '') <> coalesce(b.%[2]s, '')
+		RETURN row.from AS from, row.to AS to
+		LIMIT 1
+	`, entity, quoteIdentifier(TenantProperty))
+}
+
+// unmatchedEdgesQuery returns the rows of {from, to} with an end that does
+// not exist, which edgeQuery skips.
+func unmatchedEdgesQuery() string {
+	entity := quoteIdentifier(EntityLabel)
+	return fmt.Sprintf(`
+		UNWIND $rows AS row
+		OPTIONAL MATCH (a:%[1]s {id: row.from})
+		OPTIONAL MATCH (b:%[1]s {id: row.to})
+		WITH row, a, b
+		WHERE a IS NULL OR b IS NULL
+		RETURN row.from AS from, row.to AS to
+	`, entity)
+}
+
+// batchQuery is an UNWIND query and the rows it consumes. When check is set
+// it runs first over the same rows, and any row it returns refuses the
+// whole transaction. When unmatched is set it also runs first, and the rows
+// of {from, to} it returns are reported as edges of relType that were not
+// created.
+type batchQuery struct {
+	cypher    string
+	check     string
+	unmatched string
+	relType   string
+	rows      []map[string]interface{}
+}
+
+// writeBatches runs the queries over their rows in one session, with at
+// most batchSize rows per transaction, and returns the unmatched edges.
+func (s *Neo4jStore) writeBatches(ctx context.Context, queries []batchQuery) ([]*Edge, error) {
+	batches := splitBatches(queries, s.batchSize)
+	if len(batches) == 0 {
+		return nil, nil
+	}
+	session := s.writeSession(ctx)
 	defer session.Close(ctx)
 
-	_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
-		// Example: MATCH (a {id: $from}), (b {id: $to}) MERGE (a)-[r:TYPE]->(b) SET r += $props
-		// Note: We need to know the labels of nodes a and b ideally, or just match by ID if ID is globally unique.
-		// Assuming ID is globally unique for simplicity or we do an untyped match (slower but flexible).
-		query := fmt.Sprintf(`
-			MATCH (a {id: $from})
-			MATCH (b {id: $to})
-			MERGE (a)-[r:%s]->(b)
-			SET r += $props
-		`, edge.Type)
-
-		params := map[string]interface{}{
-			"from":  edge.FromID,
-			"to":    edge.ToID,
-			"props": edge.Properties,
+	var unmatched []*Edge
+	for _, batch := range batches {
+		edges, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+			var edges []*Edge
+			for _, q := range batch {
+				params := map[string]interface{}{"rows": q.rows}
+				if err := runCheck(ctx, tx, q.check, params); err != nil {
+					return nil, err
+				}
+				missing, err := runUnmatched(ctx, tx, q, params)
+				if err != nil {
+					return nil, err
+				}
+				edges = append(edges, missing...)
+				if _, err := tx.Run(ctx, q.cypher, params); err != nil {
+					return nil, err
+				}
+			}
+			return edges, nil
+		}, s.txConfig()...)
+		if err != nil {
+			return unmatched, err
 		}
-		_, err := tx.Run(ctx, query, params)
+		unmatched = append(unmatched, edges.([]*Edge)...)
+	}
+	return unmatched, nil
+}
+
+// runUnmatched runs the unmatched query of q, if any, and returns the edges
+// it reports.
+func runUnmatched(ctx context.Context, tx neo4j.ManagedTransaction, q batchQuery, params map[string]interface{}) ([]*Edge, error) {
+	if q.unmatched == "" {
+		return nil, nil
+	}
+	res, err := tx.Run(ctx, q.unmatched, params)
+	if err != nil {
 		return nil, err
-	})
+	}
+	records, err := res.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]*Edge, len(records))
+	for i, record := range records {
+		from, _ := record.Get("from")
+		to, _ := record.Get("to")
+		edges[i] = &Edge{FromID: fmt.Sprint(from), ToID: fmt.Sprint(to), Type: q.relType}
+	}
+	return edges, nil
+}
 
+// runCheck runs the check query of a batch, if any, and fails when it
+// returns a row.
+func runCheck(ctx context.Context, tx neo4j.ManagedTransaction, check string, params map[string]interface{}) error {
+	if check == "" {
+		return nil
+	}
+	res, err := tx.Run(ctx, check, params)
+	if err != nil {
+		return err
+	}
+	records, err := res.Collect(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to add edge: %w", err)
+		return err
+	}
+	if len(records) > 0 {
+		from, _ := records[0].Get("from")
+		to, _ := records[0].Get("to")
+		return fmt.Errorf("%w: %v -> %v", ErrCrossTenant, from, to)
 	}
 	return nil
 }
+
+// splitBatches packs the rows of the queries into transactions of at most
+// size rows. A transaction may run several queries, and a query with many
+// rows is split across transactions.
+func splitBatches(queries []batchQuery, size int) [][]batchQuery {
+	var (
+		batches [][]batchQuery
+		current []batchQuery
+		filled  int
+	)
+	for _, q := range queries {
+		rows := q.rows
+		for len(rows) > 0 {
+			n := min(len(rows), size-filled)
+			part := q
+			part.rows = rows[:n]
+			current = append(current, part)
+			rows, filled = rows[n:], filled+n
+			if filled == size {
+				batches, current, filled = append(batches, current), nil, 0
+			}
+		}
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// quoteIdentifier escapes a label or relationship type for use in Cypher.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
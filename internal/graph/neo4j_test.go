@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func rows(n int) []map[string]interface{} {
+	r := make([]map[string]interface{}, n)
+	for i := range r {
+		r[i] = map[string]interface{}{"id": fmt.Sprint(i)}
+	}
+	return r
+}
+
+func TestSplitBatches(t *testing.T) {
+	queries := []batchQuery{
+		{cypher: "A", rows: rows(5)},
+		{cypher: "B", rows: rows(2)},
+		{cypher: "C", rows: rows(4)},
+	}
+
+	// describe renders a batch as query:rows pairs, e.g. "A:4".
+	describe := func(batch []batchQuery) string {
+		s := ""
+		for i, q := range batch {
+			if i > 0 {
+				s += " "
+			}
+			s += fmt.Sprintf("%s:%d", q.cypher, len(q.rows))
+		}
+		return s
+	}
+
+	tests := []struct {
+		size int
+		want []string
+	}{
+		{4, []string{"A:4", "A:1 B:2 C:1", "C:3"}},
+		{11, []string{"A:5 B:2 C:4"}},
+		{100, []string{"A:5 B:2 C:4"}},
+		{1, []string{"A:1", "A:1", "A:1", "A:1", "A:1", "B:1", "B:1", "C:1", "C:1", "C:1", "C:1"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			batches := splitBatches(queries, tt.size)
+			if len(batches) != len(tt.want) {
+				t.Fatalf("got %d batches, want %d", len(batches), len(tt.want))
+			}
+			for i, batch := range batches {
+				if got := describe(batch); got != tt.want[i] {
+					t.Errorf("batch %d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if got := splitBatches(nil, 10); len(got) != 0 {
+		t.Errorf("expected no batches for no rows, got %v", got)
+	}
+
+	checked := splitBatches([]batchQuery{{cypher: "A", check: "X", unmatched: "U", relType: "T", rows: rows(3)}}, 2)
+	for i, batch := range checked {
+		if batch[0].check != "X" || batch[0].unmatched != "U" || batch[0].relType != "T" {
+			t.Errorf("batch %d lost its check query, unmatched query or type: %+v", i, batch[0])
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	for in, want := range map[string]string{
+		"Document":       "`Document`",
+		"DEPENDS_ON":     "`DEPENDS_ON`",
+		"x`) DETACH (n`": "`x``) DETACH (n```",
+	} {
+		if got := quoteIdentifier(in); got != want {
+			t.Errorf("quoteIdentifier(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestQueriesUseEntityLabel(t *testing.T) {
+	node := nodeQuery("Document")
+	if !strings.Contains(node, "MERGE (n:`Entity` {id: row.id})") || !strings.Contains(node, "SET n:`Document`") {
+		t.Errorf("node query does not merge on :Entity(id): %s", node)
+	}
+
+	edge := edgeQuery("IMPORTS")
+	for _, want := range []string{"MATCH (a:`Entity` {id: row.from})", "MATCH (b:`Entity` {id: row.to})", "[r:`IMPORTS`]"} {
+		if !strings.Contains(edge, want) {
+			t.Errorf("edge query lacks %q: %s", want, edge)
+		}
+	}
+}
+
+func TestCrossTenantQuery(t *testing.T) {
+	check := crossTenantQuery()
+	for _, want := range []string{
+		"MATCH (a:`Entity` {id: row.from})",
+		"MATCH (b:`Entity` {id: row.to})",
+		"coalesce(a.`tenant_id`, '') <> coalesce(b.`tenant_id`, '')",
+		"RETURN row.from AS from, row.to AS to",
+	} {
+		if !strings.Contains(check, want) {
+			t.Errorf("cross-tenant query lacks %q: %s", want, check)
+		}
+	}
+}
+
+func TestUnmatchedEdgesQuery(t *testing.T) {
+	query := unmatchedEdgesQuery()
+	for _, want := range []string{
+		"OPTIONAL MATCH (a:`Entity` {id: row.from})",
+		"OPTIONAL MATCH (b:`Entity` {id: row.to})",
+		"WHERE a IS NULL OR b IS NULL",
+		"RETURN row.from AS from, row.to AS to",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("unmatched edges query lacks %q: %s", want, query)
+		}
+	}
+
+	err := &UnmatchedEdgesError{Edges: []*Edge{{FromID: "a", ToID: "b", Type: "IMPORTS"}, {FromID: "c", ToID: "d", Type: "IMPORTS"}}}
+	if got := err.Error(); !strings.Contains(got, "2 edges") || !strings.Contains(got, "a -[IMPORTS]-> b") {
+		t.Errorf("unexpected message %q", got)
+	}
+}
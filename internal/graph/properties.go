@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// neo4jProperties adapts metadata to Neo4j property rules: properties are
+// primitives, temporal values or homogeneous lists of primitives. Nested maps
+// are flattened into dotted keys ("author.name"), and other lists are stored
+// as their JSON encoding.
+func neo4jProperties(props map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(props))
+	flattenProperties(out, "", props)
+	return out
+}
+
+func flattenProperties(out map[string]interface{}, prefix string, props map[string]interface{}) {
+	for k, v := range props {
+		key := prefix + k
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenProperties(out, key+".", nested)
+			continue
+		}
+		out[key] = propertyValue(v)
+	}
+}
+
+func propertyValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 || homogeneousPrimitives(rv) {
+			return v
+		}
+	case reflect.Map, reflect.Struct:
+		if _, ok := v.(time.Time); ok {
+			return v
+		}
+	default:
+		return v
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// homogeneousPrimitives reports whether every element of the list has the
+// same primitive kind, which Neo4j can store as a list property.
+func homogeneousPrimitives(rv reflect.Value) bool {
+	var kind reflect.Kind
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Interface {
+			if elem.IsNil() {
+				return false
+			}
+			elem = elem.Elem()
+		}
+		k := primitiveKind(elem.Kind())
+		if k == reflect.Invalid || (i > 0 && k != kind) {
+			return false
+		}
+		kind = k
+	}
+	return true
+}
+
+// primitiveKind folds numeric kinds into Int64 and Float64, the two numeric
+// types Neo4j lists can hold.
+func primitiveKind(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.String, reflect.Bool:
+		return k
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.Int64
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	default:
+		return reflect.Invalid
+	}
+}
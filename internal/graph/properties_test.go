@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNeo4jProperties(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"primitives", map[string]interface{}{"a": "x", "b": int64(1), "c": nil}, map[string]interface{}{"a": "x", "b": int64(1), "c": nil}},
+		{"timestamp", map[string]interface{}{"at": ts}, map[string]interface{}{"at": ts}},
+		{"string list", map[string]interface{}{"tags": []interface{}{"go", "rag"}}, map[string]interface{}{"tags": []interface{}{"go", "rag"}}},
+		{"mixed list", map[string]interface{}{"l": []interface{}{"a", 1}}, map[string]interface{}{"l": `["a",1]`}},
+		{"list of maps", map[string]interface{}{"l": []interface{}{map[string]interface{}{"n": 1}}}, map[string]interface{}{"l": `[{"n":1}]`}},
+		{"nested map", map[string]interface{}{
+			"author": map[string]interface{}{"name": "Ada", "org": map[string]interface{}{"id": 3}},
+		}, map[string]interface{}{"author.name": "Ada", "author.org.id": 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := neo4jProperties(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// EntityLabel is carried by every node next to its own label. Node IDs are
+// unique across labels, so the uniqueness constraint on :Entity(id) serves
+// both node merges and the endpoint lookups of edges.
+const EntityLabel = "Entity"
+
+// entityConstraint names the uniqueness constraint on :Entity(id).
+const entityConstraint = "entity_id"
+
+// TenantProperty holds the tenant of a node. Edges are only added between
+// nodes of the same tenant.
+const TenantProperty = "tenant_id"
+
+// tenantIndex names the index on :Entity(tenant_id).
+const tenantIndex = "entity_tenant"
+
+// ErrCrossTenant is returned by AddEdges when an edge would join nodes of
+// different tenants. No edge of the transaction is written.
+var ErrCrossTenant = errors.New("refusing to link nodes of different tenants")
+
+// EnsureSchema creates the uniqueness constraint on :Entity(id) and the
+// index on :Entity(tenant_id) if they do not exist yet. Nodes written before
+// the constraint are labelled :Entity first; if two of them share an id the
+// constraint cannot be created and the duplicates must be merged by hand.
+//
+// Schema changes run outside the configured query timeout, since labelling
+// a large graph can take a while.
+func (s *Neo4jStore) EnsureSchema(ctx context.Context) error {
+	session := s.writeSession(ctx)
+	defer session.Close(ctx)
+
+	res, err := session.Run(ctx, "SHOW CONSTRAINTS YIELD name WHERE name = $name RETURN name",
+		map[string]interface{}{"name": entityConstraint})
+	if err != nil {
+		return fmt.Errorf("failed to list constraints: %w", err)
+	}
+	records, err := res.Collect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list constraints: %w", err)
+	}
+	if len(records) == 0 {
+		if err := s.createEntityConstraint(ctx, session); err != nil {
+			return err
+		}
+	}
+
+	res, err = session.Run(ctx, fmt.Sprintf("CREATE INDEX %s IF NOT EXISTS FOR (n:%s) ON (n.%s)",
+		quoteIdentifier(tenantIndex), quoteIdentifier(EntityLabel), quoteIdentifier(TenantProperty)), nil)
+	if err == nil {
+		_, err = res.Consume(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create index on :%s(%s): %w", EntityLabel, TenantProperty, err)
+	}
+	return nil
+}
+
+// createEntityConstraint labels the existing nodes :Entity and creates the
+// uniqueness constraint on :Entity(id).
+func (s *Neo4jStore) createEntityConstraint(ctx context.Context, session neo4j.SessionWithContext) error {
+	// CALL IN TRANSACTIONS needs an implicit (auto-commit) transaction.
+	res, err := session.Run(ctx, fmt.Sprintf(`
+		MATCH (n) WHERE n.id IS NOT NULL AND NOT n:%[1]s
+		CALL { WITH n SET n:%[1]s } IN TRANSACTIONS OF %[2]d ROWS
+	`, quoteIdentifier(EntityLabel), s.batchSize), nil)
+	if err == nil {
+		_, err = res.Consume(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to label existing nodes :%s: %w", EntityLabel, err)
+	}
+
+	res, err = session.Run(ctx, fmt.Sprintf("CREATE CONSTRAINT %s IF NOT EXISTS FOR (n:%s) REQUIRE n.id IS UNIQUE",
+		quoteIdentifier(entityConstraint), quoteIdentifier(EntityLabel)), nil)
+	if err == nil {
+		_, err = res.Consume(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create constraint on :%s(id): %w", EntityLabel, err)
+	}
+	return nil
+}
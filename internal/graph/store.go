@@ -1,6 +1,9 @@
 package graph
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Node represents a node in the property graph.
 type Node struct {
@@ -23,4 +26,22 @@ type Store interface {
 	AddNode(ctx context.Context, node *Node) error
 	// AddEdge adds or updates an edge between two nodes.
 	AddEdge(ctx context.Context, edge *Edge) error
+	// AddNodes adds or updates many nodes at once.
+	AddNodes(ctx context.Context, nodes []*Node) error
+	// AddEdges adds or updates many edges at once. Edges whose nodes do
+	// not exist are not created and are reported by an
+	// *UnmatchedEdgesError; the others are.
+	AddEdges(ctx context.Context, edges []*Edge) error
+}
+
+// UnmatchedEdgesError lists the edges AddEdges did not create because a node
+// they connect does not exist.
+type UnmatchedEdgesError struct {
+	Edges []*Edge
+}
+
+func (e *UnmatchedEdgesError) Error() string {
+	first := e.Edges[0]
+	return fmt.Sprintf("%d edges not created as their nodes do not exist, e.g. %s -[%s]-> %s",
+		len(e.Edges), first.FromID, first.Type, first.ToID)
 }
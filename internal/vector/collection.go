@@ -0,0 +1,327 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// CollectionSpec describes the collection EnsureCollection creates. For an
+// existing collection the tunable parts (HNSW, on-disk storage, quantization
+// and payload indexes) are updated to match, while the distance cannot
+// change and is checked like the vector size. Zero values select Qdrant's
+// defaults for a new collection and leave an existing one unchanged.
+type CollectionSpec struct {
+	// Distance is "cosine" (the default), "dot", "euclid" or "manhattan".
+	Distance string
+	// HNSWM is the number of edges per node in the HNSW graph.
+	HNSWM uint64
+	// HNSWEfConstruct is the candidate list size used while building the index.
+	HNSWEfConstruct uint64
+	// OnDisk serves the original vectors from disk instead of RAM. Vectors
+	// already on disk stay there when it is false.
+	OnDisk bool
+	// Quantization is "scalar" (int8), "binary" or "none", which disables
+	// it. Quantized vectors stay in RAM, so with OnDisk this keeps large
+	// collections in memory budget.
+	Quantization string
+	// PayloadIndexes maps payload fields to their index type: "keyword",
+	// "integer", "float", "bool", "datetime", "text" or "uuid".
+	PayloadIndexes map[string]string
+}
+
+// WithCollectionSpec sets the spec applied by EnsureCollection.
+func WithCollectionSpec(spec CollectionSpec) QdrantOption {
+	return func(s *QdrantStore) { s.spec = spec }
+}
+
+var distances = map[string]pb.Distance{
+	"":          pb.Distance_Cosine,
+	"cosine":    pb.Distance_Cosine,
+	"dot":       pb.Distance_Dot,
+	"euclid":    pb.Distance_Euclid,
+	"manhattan": pb.Distance_Manhattan,
+}
+
+var fieldTypes = map[string]pb.FieldType{
+	"keyword":  pb.FieldType_FieldTypeKeyword,
+	"integer":  pb.FieldType_FieldTypeInteger,
+	"float":    pb.FieldType_FieldTypeFloat,
+	"bool":     pb.FieldType_FieldTypeBool,
+	"datetime": pb.FieldType_FieldTypeDatetime,
+	"text":     pb.FieldType_FieldTypeText,
+	"uuid":     pb.FieldType_FieldTypeUuid,
+}
+
+// schemaTypes maps index types to the type reported in the payload schema.
+var schemaTypes = map[string]pb.PayloadSchemaType{
+	"keyword":  pb.PayloadSchemaType_Keyword,
+	"integer":  pb.PayloadSchemaType_Integer,
+	"float":    pb.PayloadSchemaType_Float,
+	"bool":     pb.PayloadSchemaType_Bool,
+	"datetime": pb.PayloadSchemaType_Datetime,
+	"text":     pb.PayloadSchemaType_Text,
+	"uuid":     pb.PayloadSchemaType_Uuid,
+}
+
+// Validate reports unknown distances, quantization modes and index types.
+func (spec CollectionSpec) Validate() error {
+	if _, ok := distances[spec.Distance]; !ok {
+		return fmt.Errorf("unknown distance %q (want cosine, dot, euclid or manhattan)", spec.Distance)
+	}
+	switch spec.Quantization {
+	case "", "none", "scalar", "binary":
+	default:
+		return fmt.Errorf("unknown quantization %q (want scalar, binary or none)", spec.Quantization)
+	}
+	for field, typ := range spec.PayloadIndexes {
+		if _, ok := fieldTypes[typ]; !ok {
+			return fmt.Errorf("unknown index type %q for payload field %s", typ, field)
+		}
+	}
+	return nil
+}
+
+func (spec CollectionSpec) distance() pb.Distance {
+	if d, ok := distances[spec.Distance]; ok {
+		return d
+	}
+	return pb.Distance_Cosine
+}
+
+func (spec CollectionSpec) hnswConfig() *pb.HnswConfigDiff {
+	if spec.HNSWM == 0 && spec.HNSWEfConstruct == 0 {
+		return nil
+	}
+	cfg := &pb.HnswConfigDiff{}
+	if spec.HNSWM > 0 {
+		cfg.M = &spec.HNSWM
+	}
+	if spec.HNSWEfConstruct > 0 {
+		cfg.EfConstruct = &spec.HNSWEfConstruct
+	}
+	return cfg
+}
+
+func (spec CollectionSpec) quantizationConfig() *pb.QuantizationConfig {
+	alwaysRAM := true
+	switch spec.Quantization {
+	case "scalar":
+		return &pb.QuantizationConfig{Quantization: &pb.QuantizationConfig_Scalar{
+			Scalar: &pb.ScalarQuantization{Type: pb.QuantizationType_Int8, AlwaysRam: &alwaysRAM},
+		}}
+	case "binary":
+		return &pb.QuantizationConfig{Quantization: &pb.QuantizationConfig_Binary{
+			Binary: &pb.BinaryQuantization{AlwaysRam: &alwaysRAM},
+		}}
+	default:
+		return nil
+	}
+}
+
+// quantizationName names the quantization of a collection as in the spec.
+func quantizationName(cfg *pb.QuantizationConfig) string {
+	switch {
+	case cfg.GetScalar() != nil:
+		return "scalar"
+	case cfg.GetBinary() != nil:
+		return "binary"
+	case cfg.GetProduct() != nil:
+		return "product"
+	default:
+		return ""
+	}
+}
+
+func (s *QdrantStore) createCollection(ctx context.Context) error {
+	onDisk := s.spec.OnDisk
+	params := func() *pb.VectorParams {
+		return &pb.VectorParams{Size: s.vectorSize, Distance: s.spec.distance(), OnDisk: &onDisk}
+	}
+
+	vectorsConfig := &pb.VectorsConfig{Config: &pb.VectorsConfig_Params{Params: params()}}
+	if len(s.namedVectors) > 0 {
+		named := make(map[string]*pb.VectorParams, len(s.namedVectors))
+		for _, name := range s.namedVectors {
+			named[name] = params()
+		}
+		vectorsConfig.Config = &pb.VectorsConfig_ParamsMap{ParamsMap: &pb.VectorParamsMap{Map: named}}
+	}
+
+	_, err := pb.NewCollectionsClient(s.conn).Create(ctx, &pb.CreateCollection{
+		CollectionName:     s.collectionName,
+		VectorsConfig:      vectorsConfig,
+		HnswConfig:         s.spec.hnswConfig(),
+		QuantizationConfig: s.spec.quantizationConfig(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	for _, field := range sortedKeys(s.spec.PayloadIndexes) {
+		if err := s.createIndex(ctx, field, s.spec.PayloadIndexes[field]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectionPlan lists what differs between the spec and a collection.
+type collectionPlan struct {
+	changes []string
+	update  *pb.UpdateCollection
+	indexes []string
+}
+
+// DiffCollection describes the changes EnsureCollection would apply to the
+// existing collection, one line per setting. It is empty when the
+// collection matches the spec.
+func (s *QdrantStore) DiffCollection(ctx context.Context) ([]string, error) {
+	info, err := s.collectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkInfo(info); err != nil {
+		return nil, err
+	}
+	return s.planUpdate(info).changes, nil
+}
+
+func (s *QdrantStore) planUpdate(info *pb.CollectionInfo) collectionPlan {
+	plan := collectionPlan{update: &pb.UpdateCollection{CollectionName: s.collectionName}}
+	updated := false
+	cfg := info.GetConfig()
+
+	current := cfg.GetHnswConfig()
+	hnsw := &pb.HnswConfigDiff{}
+	if m := s.spec.HNSWM; m > 0 && current.GetM() != m {
+		plan.changes = append(plan.changes, fmt.Sprintf("hnsw m: %d -> %d", current.GetM(), m))
+		hnsw.M = &m
+	}
+	if ef := s.spec.HNSWEfConstruct; ef > 0 && current.GetEfConstruct() != ef {
+		plan.changes = append(plan.changes, fmt.Sprintf("hnsw ef_construct: %d -> %d", current.GetEfConstruct(), ef))
+		hnsw.EfConstruct = &ef
+	}
+	if hnsw.M != nil || hnsw.EfConstruct != nil {
+		plan.update.HnswConfig = hnsw
+		updated = true
+	}
+
+	// Vectors are only moved to disk, never back.
+	onDisk := s.spec.OnDisk
+	vectorsConfig := cfg.GetParams().GetVectorsConfig()
+	if params := vectorsConfig.GetParams(); onDisk && params != nil && params.GetOnDisk() != onDisk {
+		plan.changes = append(plan.changes, fmt.Sprintf("on_disk: %v -> %v", params.GetOnDisk(), onDisk))
+		plan.update.VectorsConfig = &pb.VectorsConfigDiff{Config: &pb.VectorsConfigDiff_Params{
+			Params: &pb.VectorParamsDiff{OnDisk: &onDisk},
+		}}
+		updated = true
+	} else if named := vectorsConfig.GetParamsMap().GetMap(); onDisk && named != nil {
+		diffs := make(map[string]*pb.VectorParamsDiff)
+		for _, name := range s.namedVectors {
+			if p := named[name]; p.GetOnDisk() != onDisk {
+				plan.changes = append(plan.changes, fmt.Sprintf("%q on_disk: %v -> %v", name, p.GetOnDisk(), onDisk))
+				diffs[name] = &pb.VectorParamsDiff{OnDisk: &onDisk}
+			}
+		}
+		if len(diffs) > 0 {
+			plan.update.VectorsConfig = &pb.VectorsConfigDiff{Config: &pb.VectorsConfigDiff_ParamsMap{
+				ParamsMap: &pb.VectorParamsDiffMap{Map: diffs},
+			}}
+			updated = true
+		}
+	}
+
+	want := s.spec.Quantization
+	if want == "none" {
+		want = ""
+	}
+	if have := quantizationName(cfg.GetQuantizationConfig()); s.spec.Quantization != "" && have != want {
+		plan.changes = append(plan.changes, fmt.Sprintf("quantization: %s -> %s", orNone(have), orNone(want)))
+		diff := &pb.QuantizationConfigDiff{Quantization: &pb.QuantizationConfigDiff_Disabled{Disabled: &pb.Disabled{}}}
+		switch q := s.spec.quantizationConfig().GetQuantization().(type) {
+		case *pb.QuantizationConfig_Scalar:
+			diff.Quantization = &pb.QuantizationConfigDiff_Scalar{Scalar: q.Scalar}
+		case *pb.QuantizationConfig_Binary:
+			diff.Quantization = &pb.QuantizationConfigDiff_Binary{Binary: q.Binary}
+		}
+		plan.update.QuantizationConfig = diff
+		updated = true
+	}
+
+	schema := info.GetPayloadSchema()
+	for _, field := range sortedKeys(s.spec.PayloadIndexes) {
+		typ := s.spec.PayloadIndexes[field]
+		existing, ok := schema[field]
+		switch {
+		case !ok:
+			plan.changes = append(plan.changes, fmt.Sprintf("payload index %s: none -> %s", field, typ))
+		case existing.GetDataType() != schemaTypes[typ]:
+			plan.changes = append(plan.changes, fmt.Sprintf("payload index %s: %s -> %s",
+				field, strings.ToLower(existing.GetDataType().String()), typ))
+		default:
+			continue
+		}
+		plan.indexes = append(plan.indexes, field)
+	}
+
+	if !updated {
+		plan.update = nil
+	}
+	return plan
+}
+
+// updateCollection applies the differences between the spec and info.
+func (s *QdrantStore) updateCollection(ctx context.Context, info *pb.CollectionInfo) error {
+	plan := s.planUpdate(info)
+	for _, change := range plan.changes {
+		log.Printf("Updating collection %s: %s", s.collectionName, change)
+	}
+
+	if plan.update != nil {
+		if _, err := pb.NewCollectionsClient(s.conn).Update(ctx, plan.update); err != nil {
+			return fmt.Errorf("failed to update collection %s: %w", s.collectionName, err)
+		}
+	}
+	for _, field := range plan.indexes {
+		if err := s.createIndex(ctx, field, s.spec.PayloadIndexes[field]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *QdrantStore) createIndex(ctx context.Context, field, typ string) error {
+	fieldType := fieldTypes[typ]
+	wait := true
+	_, err := s.pointsClient.CreateFieldIndex(ctx, &pb.CreateFieldIndexCollection{
+		CollectionName: s.collectionName,
+		Wait:           &wait,
+		FieldName:      field,
+		FieldType:      &fieldType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index payload field %s: %w", field, err)
+	}
+	return nil
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
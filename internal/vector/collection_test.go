@@ -0,0 +1,120 @@
+package vector
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+func TestCollectionSpecValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec CollectionSpec
+		ok   bool
+	}{
+		{"zero", CollectionSpec{}, true},
+		{"tuned", CollectionSpec{Distance: "dot", Quantization: "binary", PayloadIndexes: map[string]string{"tenant": "keyword"}}, true},
+		{"no quantization", CollectionSpec{Quantization: "none"}, true},
+		{"bad distance", CollectionSpec{Distance: "hamming"}, false},
+		{"bad quantization", CollectionSpec{Quantization: "product"}, false},
+		{"bad index", CollectionSpec{PayloadIndexes: map[string]string{"tenant": "string"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// collectionInfoFor builds the info Qdrant reports for a collection with an
+// unnamed vector.
+func collectionInfoFor(size uint64, distance pb.Distance, onDisk bool, m uint64, quant *pb.QuantizationConfig, schema map[string]*pb.PayloadSchemaInfo) *pb.CollectionInfo {
+	return &pb.CollectionInfo{
+		Config: &pb.CollectionConfig{
+			Params: &pb.CollectionParams{VectorsConfig: &pb.VectorsConfig{Config: &pb.VectorsConfig_Params{
+				Params: &pb.VectorParams{Size: size, Distance: distance, OnDisk: &onDisk},
+			}}},
+			HnswConfig:         &pb.HnswConfigDiff{M: &m},
+			QuantizationConfig: quant,
+		},
+		PayloadSchema: schema,
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	spec := CollectionSpec{
+		HNSWM:          32,
+		OnDisk:         true,
+		Quantization:   "scalar",
+		PayloadIndexes: map[string]string{"tenant": "keyword", "updated_at": "datetime"},
+	}
+	s := &QdrantStore{collectionName: "docs", vectorSize: 3, spec: spec}
+
+	t.Run("Changes", func(t *testing.T) {
+		info := collectionInfoFor(3, pb.Distance_Cosine, false, 16, nil, map[string]*pb.PayloadSchemaInfo{
+			"tenant": {DataType: pb.PayloadSchemaType_Keyword},
+		})
+		plan := s.planUpdate(info)
+		want := []string{
+			"hnsw m: 16 -> 32",
+			"on_disk: false -> true",
+			"quantization: none -> scalar",
+			"payload index updated_at: none -> datetime",
+		}
+		if !reflect.DeepEqual(plan.changes, want) {
+			t.Errorf("changes = %q, want %q", plan.changes, want)
+		}
+		if plan.update.GetHnswConfig().GetM() != 32 || !plan.update.GetVectorsConfig().GetParams().GetOnDisk() {
+			t.Errorf("unexpected update %v", plan.update)
+		}
+		if plan.update.GetQuantizationConfig().GetScalar() == nil {
+			t.Errorf("expected scalar quantization in update, got %v", plan.update.GetQuantizationConfig())
+		}
+		if !reflect.DeepEqual(plan.indexes, []string{"updated_at"}) {
+			t.Errorf("indexes = %v", plan.indexes)
+		}
+	})
+
+	t.Run("UpToDate", func(t *testing.T) {
+		quant := spec.quantizationConfig()
+		info := collectionInfoFor(3, pb.Distance_Cosine, true, 32, quant, map[string]*pb.PayloadSchemaInfo{
+			"tenant":     {DataType: pb.PayloadSchemaType_Keyword},
+			"updated_at": {DataType: pb.PayloadSchemaType_Datetime},
+		})
+		if plan := s.planUpdate(info); len(plan.changes) != 0 || plan.update != nil || len(plan.indexes) != 0 {
+			t.Errorf("expected no changes, got %+v", plan)
+		}
+	})
+
+	t.Run("ZeroLeavesUnchanged", func(t *testing.T) {
+		plain := &QdrantStore{collectionName: "docs", vectorSize: 3}
+		info := collectionInfoFor(3, pb.Distance_Cosine, true, 16, spec.quantizationConfig(), nil)
+		if plan := plain.planUpdate(info); len(plan.changes) != 0 || plan.update != nil {
+			t.Errorf("expected an empty spec to keep on-disk storage and quantization, got %+v", plan)
+		}
+	})
+
+	t.Run("DisableQuantization", func(t *testing.T) {
+		plain := &QdrantStore{collectionName: "docs", vectorSize: 3, spec: CollectionSpec{Quantization: "none"}}
+		info := collectionInfoFor(3, pb.Distance_Cosine, false, 16, spec.quantizationConfig(), nil)
+		plan := plain.planUpdate(info)
+		if plan.update.GetQuantizationConfig().GetDisabled() == nil {
+			t.Errorf("expected quantization to be disabled, got %+v", plan)
+		}
+		if want := []string{"quantization: scalar -> none"}; !reflect.DeepEqual(plan.changes, want) {
+			t.Errorf("changes = %q, want %q", plan.changes, want)
+		}
+	})
+
+	t.Run("DistanceMismatch", func(t *testing.T) {
+		info := collectionInfoFor(3, pb.Distance_Dot, false, 16, nil, nil)
+		var mismatch *MismatchError
+		if err := s.checkInfo(info); !errors.As(err, &mismatch) || mismatch.Field != "distance" {
+			t.Errorf("expected a distance mismatch, got %v", err)
+		}
+	})
+}
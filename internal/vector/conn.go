@@ -0,0 +1,115 @@
+package vector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	pb "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+// connOptions configure the gRPC connection of a QdrantStore.
+type connOptions struct {
+	tls       bool
+	caFile    string
+	apiKey    string
+	timeout   time.Duration
+	keepalive time.Duration
+}
+
+// WithTLS encrypts the connection. caFile names a PEM bundle of CAs trusted
+// in addition to the system roots, for clusters with a private CA; empty
+// uses the system roots only.
+func WithTLS(caFile string) QdrantOption {
+	return func(s *QdrantStore) {
+		s.connOpts.tls = true
+		s.connOpts.caFile = caFile
+	}
+}
+
+// WithAPIKey sends key in the api-key header of every call, as managed
+// Qdrant clusters require.
+func WithAPIKey(key string) QdrantOption {
+	return func(s *QdrantStore) { s.connOpts.apiKey = key }
+}
+
+// WithTimeout bounds every call that has no earlier deadline.
+func WithTimeout(d time.Duration) QdrantOption {
+	return func(s *QdrantStore) { s.connOpts.timeout = d }
+}
+
+// WithKeepalive pings an idle connection at the given interval so load
+// balancers do not drop it silently.
+func WithKeepalive(interval time.Duration) QdrantOption {
+	return func(s *QdrantStore) { s.connOpts.keepalive = interval }
+}
+
+// dialOptions turns the connection options into gRPC dial options.
+func (o connOptions) dialOptions() ([]grpc.DialOption, error) {
+	creds := insecure.NewCredentials()
+	if o.tls {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if o.caFile != "" {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			pem, err := os.ReadFile(o.caFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", o.caFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		creds = credentials.NewTLS(tlsCfg)
+	} else if o.caFile != "" {
+		return nil, errors.New("a CA file requires TLS")
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(o.unaryInterceptor),
+	}
+	if o.keepalive > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                o.keepalive,
+			Timeout:             o.keepalive,
+			PermitWithoutStream: true,
+		}))
+	}
+	return opts, nil
+}
+
+// unaryInterceptor adds the API key and the default deadline to each call.
+func (o connOptions) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if o.apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "api-key", o.apiKey)
+	}
+	if _, ok := ctx.Deadline(); !ok && o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// HealthCheck asks Qdrant for its version, verifying address, TLS and API
+// key before any work starts.
+func (s *QdrantStore) HealthCheck(ctx context.Context) (version string, err error) {
+	res, err := pb.NewQdrantClient(s.conn).HealthCheck(ctx, &pb.HealthCheckRequest{})
+	if err != nil {
+		return "", fmt.Errorf("qdrant health check: %w", err)
+	}
+	return res.GetVersion(), nil
+}
@@ -0,0 +1,115 @@
+package vector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthServer answers health checks when called with the expected API key.
+type healthServer struct {
+	pb.UnimplementedQdrantServer
+	apiKey string
+	delay  time.Duration
+}
+
+func (h *healthServer) HealthCheck(ctx context.Context, _ *pb.HealthCheckRequest) (*pb.HealthCheckReply, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("api-key"); h.apiKey != "" && (len(keys) != 1 || keys[0] != h.apiKey) {
+		return nil, status.Error(codes.Unauthenticated, "bad api key")
+	}
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &pb.HealthCheckReply{Title: "qdrant", Version: "1.16.2"}, nil
+}
+
+func startHealthServer(t *testing.T, h *healthServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterQdrantServer(srv, h)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	addr := startHealthServer(t, &healthServer{apiKey: "secret", delay: 50 * time.Millisecond})
+
+	t.Run("APIKey", func(t *testing.T) {
+		s, err := NewQdrantStore(addr, "docs", 3, WithAPIKey("secret"), WithKeepalive(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		version, err := s.HealthCheck(ctx)
+		if err != nil || version != "1.16.2" {
+			t.Errorf("HealthCheck() = %q, %v", version, err)
+		}
+	})
+
+	t.Run("MissingAPIKey", func(t *testing.T) {
+		s, err := NewQdrantStore(addr, "docs", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if _, err := s.HealthCheck(ctx); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected Unauthenticated, got %v", err)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		s, err := NewQdrantStore(addr, "docs", 3, WithAPIKey("secret"), WithTimeout(10*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if _, err := s.HealthCheck(ctx); status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("expected DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestDialOptions(t *testing.T) {
+	dir := t.TempDir()
+	badCA := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(badCA, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts connOptions
+		ok   bool
+	}{
+		{"insecure", connOptions{}, true},
+		{"system roots", connOptions{tls: true}, true},
+		{"ca without tls", connOptions{caFile: badCA}, false},
+		{"missing ca", connOptions{tls: true, caFile: filepath.Join(dir, "missing.pem")}, false},
+		{"invalid ca", connOptions{tls: true, caFile: badCA}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.opts.dialOptions(); (err == nil) != tt.ok {
+				t.Errorf("dialOptions() = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package vector
+
+import (
+	"fmt"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// Filter restricts a search to points whose payload satisfies every Must
+// condition and none of the MustNot conditions. A point lacking the key of
+// a condition does not satisfy it.
+type Filter struct {
+	Must    []Condition
+	MustNot []Condition
+}
+
+// Condition tests one payload key against Match, or against Range when
+// Match is nil.
+type Condition struct {
+	Key string
+	// Match is a string, bool or integer the value must equal.
+	Match interface{}
+	Range *Range
+}
+
+// Range bounds a numeric payload value; nil bounds are open.
+type Range struct {
+	GT, GTE, LT, LTE *float64
+}
+
+// toPbFilter converts f to a Qdrant filter; a nil f filters nothing.
+func toPbFilter(f *Filter) (*pb.Filter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	must, err := toPbConditions(f.Must)
+	if err != nil {
+		return nil, err
+	}
+	mustNot, err := toPbConditions(f.MustNot)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Filter{Must: must, MustNot: mustNot}, nil
+}
+
+func toPbConditions(conditions []Condition) ([]*pb.Condition, error) {
+	out := make([]*pb.Condition, len(conditions))
+	for i, c := range conditions {
+		field := &pb.FieldCondition{Key: c.Key}
+		switch v := c.Match.(type) {
+		case nil:
+			if c.Range == nil {
+				return nil, fmt.Errorf("condition on %s has neither a match nor a range", c.Key)
+			}
+			field.Range = &pb.Range{Gt: c.Range.GT, Gte: c.Range.GTE, Lt: c.Range.LT, Lte: c.Range.LTE}
+		case string:
+			field.Match = &pb.Match{MatchValue: &pb.Match_Keyword{Keyword: v}}
+		case bool:
+			field.Match = &pb.Match{MatchValue: &pb.Match_Boolean{Boolean: v}}
+		case int:
+			field.Match = &pb.Match{MatchValue: &pb.Match_Integer{Integer: int64(v)}}
+		case int64:
+			field.Match = &pb.Match{MatchValue: &pb.Match_Integer{Integer: v}}
+		default:
+			return nil, fmt.Errorf("condition on %s: cannot match %T", c.Key, c.Match)
+		}
+		out[i] = &pb.Condition{ConditionOneOf: &pb.Condition_Field{Field: field}}
+	}
+	return out, nil
+}
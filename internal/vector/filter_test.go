@@ -0,0 +1,44 @@
+package vector
+
+import "testing"
+
+func TestToPbFilter(t *testing.T) {
+	if f, err := toPbFilter(nil); f != nil || err != nil {
+		t.Errorf("toPbFilter(nil) = %v, %v", f, err)
+	}
+
+	at := 1700000000.0
+	f, err := toPbFilter(&Filter{
+		Must: []Condition{
+			{Key: "valid_from", Range: &Range{LTE: &at}},
+			{Key: "source", Match: "wiki"},
+			{Key: "version", Match: 3},
+		},
+		MustNot: []Condition{{Key: "latest", Match: false}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	must := f.GetMust()
+	if len(must) != 3 {
+		t.Fatalf("expected 3 must conditions, got %d", len(must))
+	}
+	if r := must[0].GetField().GetRange(); r.GetLte() != at || r.Gt != nil {
+		t.Errorf("unexpected range %v", r)
+	}
+	if m := must[1].GetField().GetMatch(); m.GetKeyword() != "wiki" {
+		t.Errorf("unexpected keyword match %v", m)
+	}
+	if m := must[2].GetField().GetMatch(); m.GetInteger() != 3 {
+		t.Errorf("unexpected integer match %v", m)
+	}
+	if c := f.GetMustNot(); len(c) != 1 || c[0].GetField().GetKey() != "latest" || c[0].GetField().GetMatch().GetBoolean() {
+		t.Errorf("unexpected must_not %v", c)
+	}
+
+	for _, bad := range []Condition{{Key: "empty"}, {Key: "float", Match: 1.5}} {
+		if _, err := toPbFilter(&Filter{Must: []Condition{bad}}); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package vector
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// ExternalIDKey is the payload key preserving the caller's ID of points whose
+// ID is not a valid Qdrant point ID.
+const ExternalIDKey = "external_id"
+
+// idNamespace scopes the UUIDv5 IDs derived from external IDs.
+var idNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/bondzai/grextor/point-id"))
+
+// PointUUID returns the Qdrant point ID stored for an external ID such as a
+// file path or ticket number. The mapping is deterministic, so re-ingesting a
+// document overwrites its point.
+func PointUUID(id string) string {
+	return uuid.NewSHA1(idNamespace, []byte(id)).String()
+}
+
+// toPointID maps an ID to a Qdrant point ID. Canonical UUIDs and unsigned
+// integers are used as-is; any other ID becomes a UUIDv5 and must be kept in
+// the payload under ExternalIDKey.
+func toPointID(id string) (pid *pb.PointId, mapped bool) {
+	if u, err := uuid.Parse(id); err == nil && u.String() == id {
+		return &pb.PointId{PointIdOptions: &pb.PointId_Uuid{Uuid: id}}, false
+	}
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil && strconv.FormatUint(n, 10) == id {
+		return &pb.PointId{PointIdOptions: &pb.PointId_Num{Num: n}}, false
+	}
+	return &pb.PointId{PointIdOptions: &pb.PointId_Uuid{Uuid: PointUUID(id)}}, true
+}
+
+// externalID returns the caller's ID of a stored point and removes the
+// bookkeeping key from its metadata.
+func externalID(id *pb.PointId, meta map[string]interface{}) string {
+	if ext, ok := meta[ExternalIDKey].(string); ok {
+		delete(meta, ExternalIDKey)
+		return ext
+	}
+	return pointIDString(id)
+}
@@ -0,0 +1,53 @@
+package vector
+
+import (
+	"testing"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+func TestToPointID(t *testing.T) {
+	tests := []struct {
+		id     string
+		want   string
+		mapped bool
+	}{
+		{"6f1c8e0a-7c9b-4b7e-9a51-0f3b8d2f4c11", "6f1c8e0a-7c9b-4b7e-9a51-0f3b8d2f4c11", false},
+		{"42", "42", false},
+		{"042", PointUUID("042"), true},
+		{"6F1C8E0A-7C9B-4B7E-9A51-0F3B8D2F4C11", PointUUID("6F1C8E0A-7C9B-4B7E-9A51-0F3B8D2F4C11"), true},
+		{"docs/architecture.md", PointUUID("docs/architecture.md"), true},
+		{"JIRA-1234", PointUUID("JIRA-1234"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			pid, mapped := toPointID(tt.id)
+			if got := pointIDString(pid); got != tt.want || mapped != tt.mapped {
+				t.Errorf("toPointID(%q) = %s, %v; want %s, %v", tt.id, got, mapped, tt.want, tt.mapped)
+			}
+		})
+	}
+
+	if PointUUID("JIRA-1234") != PointUUID("JIRA-1234") {
+		t.Error("PointUUID is not deterministic")
+	}
+	if PointUUID("a") == PointUUID("b") {
+		t.Error("PointUUID collides for different IDs")
+	}
+}
+
+func TestExternalID(t *testing.T) {
+	pid, _ := toPointID("JIRA-1234")
+	meta := map[string]interface{}{ExternalIDKey: "JIRA-1234", "content": "x"}
+	if got := externalID(pid, meta); got != "JIRA-1234" {
+		t.Errorf("expected the external ID, got %s", got)
+	}
+	if _, ok := meta[ExternalIDKey]; ok {
+		t.Error("expected the external ID key to be removed from metadata")
+	}
+
+	num := &pb.PointId{PointIdOptions: &pb.PointId_Num{Num: 7}}
+	if got := externalID(num, map[string]interface{}{}); got != "7" {
+		t.Errorf("expected the point ID, got %s", got)
+	}
+}
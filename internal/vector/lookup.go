@@ -0,0 +1,134 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// GetPoints implements PointLookup.
+func (s *QdrantStore) GetPoints(ctx context.Context, ids []string, withVectors bool) ([]*Point, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	pids := make([]*pb.PointId, len(ids))
+	for i, id := range ids {
+		pids[i], _ = toPointID(id)
+	}
+	res, err := s.pointsClient.Get(ctx, &pb.GetPoints{
+		CollectionName: s.collectionName,
+		Ids:            pids,
+		WithPayload:    &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}},
+		WithVectors:    &pb.WithVectorsSelector{SelectorOptions: &pb.WithVectorsSelector_Enable{Enable: withVectors}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get points from %s: %w", s.collectionName, err)
+	}
+	points := toPoints(res.Result)
+	if withVectors {
+		for i, r := range res.Result {
+			s.setVectors(points[i], r.Vectors)
+		}
+	}
+	return points, nil
+}
+
+// setVectors copies returned vectors into p, mapping the default vector of
+// a multi-vector collection to p.Vector.
+func (s *QdrantStore) setVectors(p *Point, v *pb.VectorsOutput) {
+	if len(s.namedVectors) == 0 {
+		p.Vector = vectorData(v.GetVector())
+		return
+	}
+	for name, vec := range v.GetVectors().GetVectors() {
+		if name == s.defaultVector {
+			p.Vector = vectorData(vec)
+			continue
+		}
+		if p.Vectors == nil {
+			p.Vectors = make(map[string][]float32)
+		}
+		p.Vectors[name] = vectorData(vec)
+	}
+}
+
+// FindPoints implements PointLookup.
+func (s *QdrantStore) FindPoints(ctx context.Context, filter *Filter, limit int) ([]*Point, error) {
+	pbFilter, err := toPbFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	l := uint32(limit)
+	res, err := s.pointsClient.Scroll(ctx, &pb.ScrollPoints{
+		CollectionName: s.collectionName,
+		Filter:         pbFilter,
+		Limit:          &l,
+		WithPayload:    &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find points in %s: %w", s.collectionName, err)
+	}
+	return toPoints(res.Result), nil
+}
+
+// UpdatePayloads implements PointLookup with one batch request.
+func (s *QdrantStore) UpdatePayloads(ctx context.Context, points []*Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	ops := make([]*pb.PointsUpdateOperation, len(points))
+	for i, p := range points {
+		id, payload, err := toPayload(p)
+		if err != nil {
+			return err
+		}
+		ops[i] = &pb.PointsUpdateOperation{
+			Operation: &pb.PointsUpdateOperation_OverwritePayload_{OverwritePayload: &pb.PointsUpdateOperation_OverwritePayload{
+				Payload: payload,
+				PointsSelector: &pb.PointsSelector{PointsSelectorOneOf: &pb.PointsSelector_Points{
+					Points: &pb.PointsIdsList{Ids: []*pb.PointId{id}},
+				}},
+			}},
+		}
+	}
+	_, err := s.pointsClient.UpdateBatch(ctx, &pb.UpdateBatchPoints{
+		CollectionName: s.collectionName,
+		Operations:     ops,
+	})
+	return err
+}
+
+// DeletePoints implements PointLookup.
+func (s *QdrantStore) DeletePoints(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pids := make([]*pb.PointId, len(ids))
+	for i, id := range ids {
+		pids[i], _ = toPointID(id)
+	}
+	_, err := s.pointsClient.Delete(ctx, &pb.DeletePoints{
+		CollectionName: s.collectionName,
+		Points: &pb.PointsSelector{PointsSelectorOneOf: &pb.PointsSelector_Points{
+			Points: &pb.PointsIdsList{Ids: pids},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete points from %s: %w", s.collectionName, err)
+	}
+	return nil
+}
+
+// toPoints converts retrieved points, leaving out their vectors.
+func toPoints(results []*pb.RetrievedPoint) []*Point {
+	points := make([]*Point, len(results))
+	for i, r := range results {
+		meta := fromPayload(r.Payload)
+		points[i] = &Point{
+			ID:       externalID(r.Id, meta),
+			Metadata: meta,
+		}
+	}
+	return points
+}
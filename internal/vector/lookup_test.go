@@ -0,0 +1,147 @@
+package vector
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// pointsServer serves stored payloads and records payload updates and
+// deletions.
+type pointsServer struct {
+	pb.UnimplementedPointsServer
+	payloads map[string]map[string]*pb.Value
+	filter   *pb.Filter
+	updates  []*pb.PointsUpdateOperation
+	deleted  []*pb.PointId
+}
+
+func (s *pointsServer) Get(_ context.Context, req *pb.GetPoints) (*pb.GetResponse, error) {
+	res := &pb.GetResponse{}
+	for _, id := range req.Ids {
+		if payload, ok := s.payloads[pointIDString(id)]; ok {
+			p := &pb.RetrievedPoint{Id: id, Payload: payload}
+			if req.WithVectors.GetEnable() {
+				p.Vectors = &pb.VectorsOutput{VectorsOptions: &pb.VectorsOutput_Vector{
+					Vector: &pb.VectorOutput{Vector: &pb.VectorOutput_Dense{Dense: &pb.DenseVector{Data: []float32{1, 2, 3}}}},
+				}}
+			}
+			res.Result = append(res.Result, p)
+		}
+	}
+	return res, nil
+}
+
+func (s *pointsServer) Scroll(_ context.Context, req *pb.ScrollPoints) (*pb.ScrollResponse, error) {
+	s.filter = req.Filter
+	return &pb.ScrollResponse{}, nil
+}
+
+func (s *pointsServer) UpdateBatch(_ context.Context, req *pb.UpdateBatchPoints) (*pb.UpdateBatchResponse, error) {
+	s.updates = append(s.updates, req.Operations...)
+	return &pb.UpdateBatchResponse{}, nil
+}
+
+func (s *pointsServer) Delete(_ context.Context, req *pb.DeletePoints) (*pb.PointsOperationResponse, error) {
+	s.deleted = append(s.deleted, req.GetPoints().GetPoints().GetIds()...)
+	return &pb.PointsOperationResponse{}, nil
+}
+
+func startPointsServer(t *testing.T, s *pointsServer) *QdrantStore {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterPointsServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	store, err := NewQdrantStore(lis.Addr().String(), "docs", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestPointLookup(t *testing.T) {
+	ctx := context.Background()
+	mapped := PointUUID("docs/a.md")
+	server := &pointsServer{payloads: map[string]map[string]*pb.Value{
+		mapped: {
+			"content_hash": toPbValue("abc"),
+			ExternalIDKey:  toPbValue("docs/a.md"),
+		},
+	}}
+	store := startPointsServer(t, server)
+
+	points, err := store.GetPoints(ctx, []string{"docs/a.md", "docs/missing.md"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].ID != "docs/a.md" || points[0].Metadata["content_hash"] != "abc" {
+		t.Errorf("GetPoints() = %v, want docs/a.md with its payload", points)
+	}
+	if _, ok := points[0].Metadata[ExternalIDKey]; ok {
+		t.Errorf("expected %s to be stripped from the metadata", ExternalIDKey)
+	}
+	if points[0].Vector != nil {
+		t.Errorf("expected no vector, got %v", points[0].Vector)
+	}
+	points, err = store.GetPoints(ctx, []string{"docs/a.md"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || len(points[0].Vector) != 3 {
+		t.Errorf("expected the stored vector, got %v", points)
+	}
+
+	filter := &Filter{Must: []Condition{{Key: "content_hash", Match: "abc"}}}
+	if _, err := store.FindPoints(ctx, filter, 2); err != nil {
+		t.Fatal(err)
+	}
+	match := server.filter.GetMust()[0].GetField()
+	if match.GetKey() != "content_hash" || match.GetMatch().GetKeyword() != "abc" {
+		t.Errorf("unexpected filter %v", server.filter)
+	}
+
+	err = store.UpdatePayloads(ctx, []*Point{{ID: "docs/a.md", Metadata: map[string]interface{}{"source": "new"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.updates) != 1 {
+		t.Fatalf("expected one update, got %d", len(server.updates))
+	}
+	op := server.updates[0].GetOverwritePayload()
+	if ids := op.GetPointsSelector().GetPoints().GetIds(); len(ids) != 1 || ids[0].GetUuid() != mapped {
+		t.Errorf("expected the update to select %s, got %v", mapped, ids)
+	}
+	if got := op.GetPayload(); got["source"].GetStringValue() != "new" || got[ExternalIDKey].GetStringValue() != "docs/a.md" {
+		t.Errorf("unexpected payload %v", got)
+	}
+
+	for _, key := range ReservedKeys {
+		err = store.UpdatePayloads(ctx, []*Point{{ID: "docs/a.md", Metadata: map[string]interface{}{key: "mine"}}})
+		if err == nil {
+			t.Errorf("expected metadata key %s to be rejected", key)
+		}
+	}
+	if err := store.Upsert(ctx, []*Point{{ID: "docs/a.md", Vector: []float32{1, 2, 3}, Metadata: map[string]interface{}{ExternalIDKey: "x"}}}); err == nil {
+		t.Errorf("expected Upsert to reject metadata key %s", ExternalIDKey)
+	}
+	if len(server.updates) != 1 {
+		t.Errorf("expected rejected payloads not to be sent, got %d updates", len(server.updates))
+	}
+
+	if err := store.DeletePoints(ctx, []string{"docs/a.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.deleted) != 1 || server.deleted[0].GetUuid() != mapped {
+		t.Errorf("expected %s to be deleted, got %v", mapped, server.deleted)
+	}
+}
@@ -0,0 +1,308 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// Payload conversion.
+//
+// Qdrant payloads are JSON-like: null, bool, integer, double, string, list
+// and struct. Go values are mapped as follows:
+//
+//   - nil and nil pointers become null
+//   - signed and unsigned integers become integers; uint64 values above
+//     math.MaxInt64 become doubles
+//   - float32 is widened by its shortest decimal form, so 0.1 stays 0.1
+//   - time.Time becomes an RFC 3339 string, the format Qdrant's datetime
+//     index expects. Point payloads list the paths of such strings under
+//     TimestampPathsKey, and only those decode back to time.Time (in UTC);
+//     other strings stay strings, whatever they look like
+//   - slices and arrays become lists, maps with string keys become structs
+//   - anything else, such as a Go struct, is converted through its JSON form
+//
+// Decoding yields string, bool, int64, float64, time.Time, nil,
+// []interface{} and map[string]interface{}.
+
+// TimestampPathsKey is the payload key listing the paths of the time.Time
+// values of a point, each as a list of map keys and list indexes.
+const TimestampPathsKey = "timestamp_paths"
+
+// ReservedKeys are the payload keys the store writes itself. Metadata using
+// them is rejected, as it would be overwritten on write or dropped on read.
+var ReservedKeys = []string{ExternalIDKey, TimestampPathsKey}
+
+// CheckMetadata rejects metadata using one of the ReservedKeys.
+func CheckMetadata(meta map[string]interface{}) error {
+	for _, key := range ReservedKeys {
+		if _, ok := meta[key]; ok {
+			return fmt.Errorf("metadata key %q is reserved", key)
+		}
+	}
+	return nil
+}
+
+// encodePayload converts metadata to a Qdrant payload, recording where it
+// holds time.Time values.
+func encodePayload(meta map[string]interface{}) map[string]*pb.Value {
+	enc := &payloadEncoder{}
+	payload := make(map[string]*pb.Value, len(meta)+1)
+	for k, v := range meta {
+		payload[k] = enc.value(v, []string{k})
+	}
+	if len(enc.timestamps) > 0 {
+		payload[TimestampPathsKey] = toPbValue(enc.timestamps)
+	}
+	return payload
+}
+
+// fromPayload converts a Qdrant payload to metadata, restoring the
+// time.Time values recorded by encodePayload.
+func fromPayload(payload map[string]*pb.Value) map[string]interface{} {
+	meta := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if k != TimestampPathsKey {
+			meta[k] = fromPbValue(v)
+		}
+	}
+	paths, _ := fromPbValue(payload[TimestampPathsKey]).([]interface{})
+	for _, path := range paths {
+		if segments, ok := path.([]interface{}); ok {
+			restoreTimestamp(meta, segments)
+		}
+	}
+	return meta
+}
+
+// restoreTimestamp parses the string at path in meta back into a time.Time.
+// Paths that no longer lead to a timestamp string are ignored.
+func restoreTimestamp(meta map[string]interface{}, path []interface{}) {
+	if len(path) == 0 {
+		return
+	}
+	var (
+		parent interface{} = meta
+		set    func(interface{})
+		value  interface{}
+	)
+	for _, seg := range path {
+		key, _ := seg.(string)
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			v, ok := p[key]
+			if !ok {
+				return
+			}
+			value, set = v, func(v interface{}) { p[key] = v }
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(p) {
+				return
+			}
+			value, set = p[i], func(v interface{}) { p[i] = v }
+		default:
+			return
+		}
+		parent = value
+	}
+	s, ok := value.(string)
+	if !ok {
+		return
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		set(t.UTC())
+	}
+}
+
+// payloadEncoder converts values and collects the paths of timestamps.
+type payloadEncoder struct {
+	timestamps [][]string
+}
+
+// toPbValue converts a Go value to a Qdrant payload value.
+func toPbValue(v interface{}) *pb.Value {
+	return (&payloadEncoder{}).value(v, nil)
+}
+
+// value converts v, found at path.
+func (enc *payloadEncoder) value(v interface{}, path []string) *pb.Value {
+	switch val := v.(type) {
+	case nil:
+		return nullValue()
+	case string:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: val}}
+	case bool:
+		return &pb.Value{Kind: &pb.Value_BoolValue{BoolValue: val}}
+	case int:
+		return intValue(int64(val))
+	case int8:
+		return intValue(int64(val))
+	case int16:
+		return intValue(int64(val))
+	case int32:
+		return intValue(int64(val))
+	case int64:
+		return intValue(val)
+	case uint:
+		return uintValue(uint64(val))
+	case uint8:
+		return intValue(int64(val))
+	case uint16:
+		return intValue(int64(val))
+	case uint32:
+		return intValue(int64(val))
+	case uint64:
+		return uintValue(val)
+	case float32:
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(val), 'g', -1, 32), 64)
+		return doubleValue(f)
+	case float64:
+		return doubleValue(val)
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return intValue(n)
+		}
+		f, _ := val.Float64()
+		return doubleValue(f)
+	case time.Time:
+		if path != nil {
+			enc.timestamps = append(enc.timestamps, path)
+		}
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: val.UTC().Format(time.RFC3339Nano)}}
+	case []interface{}:
+		values := make([]*pb.Value, len(val))
+		for i, item := range val {
+			values[i] = enc.value(item, childPath(path, strconv.Itoa(i)))
+		}
+		return &pb.Value{Kind: &pb.Value_ListValue{ListValue: &pb.ListValue{Values: values}}}
+	case map[string]interface{}:
+		return enc.structValue(val, path)
+	case *pb.Value:
+		return val
+	}
+	return enc.reflectValue(reflect.ValueOf(v), path)
+}
+
+// childPath extends path by seg; a nil path records nothing.
+func childPath(path []string, seg string) []string {
+	if path == nil {
+		return nil
+	}
+	return append(path[:len(path):len(path)], seg)
+}
+
+// reflectValue handles pointers, typed slices and maps, and falls back to
+// the JSON form of any other value.
+func (enc *payloadEncoder) reflectValue(rv reflect.Value, path []string) *pb.Value {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nullValue()
+		}
+		return enc.value(rv.Elem().Interface(), path)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nullValue()
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte marshals to base64 in JSON; keep that convention.
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		values := make([]*pb.Value, rv.Len())
+		for i := range values {
+			values[i] = enc.value(rv.Index(i).Interface(), childPath(path, strconv.Itoa(i)))
+		}
+		return &pb.Value{Kind: &pb.Value_ListValue{ListValue: &pb.ListValue{Values: values}}}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if rv.IsNil() {
+			return nullValue()
+		}
+		fields := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			fields[iter.Key().String()] = iter.Value().Interface()
+		}
+		return enc.structValue(fields, path)
+	}
+
+	data, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: fmt.Sprintf("%v", rv.Interface())}}
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: string(data)}}
+	}
+	return enc.value(generic, path)
+}
+
+// fromPbValue converts a Qdrant payload value to a Go value.
+func fromPbValue(v *pb.Value) interface{} {
+	switch k := v.GetKind().(type) {
+	case *pb.Value_StringValue:
+		return k.StringValue
+	case *pb.Value_IntegerValue:
+		return k.IntegerValue
+	case *pb.Value_DoubleValue:
+		return k.DoubleValue
+	case *pb.Value_BoolValue:
+		return k.BoolValue
+	case *pb.Value_ListValue:
+		values := k.ListValue.GetValues()
+		list := make([]interface{}, len(values))
+		for i, item := range values {
+			list[i] = fromPbValue(item)
+		}
+		return list
+	case *pb.Value_StructValue:
+		fields := k.StructValue.GetFields()
+		m := make(map[string]interface{}, len(fields))
+		for name, item := range fields {
+			m[name] = fromPbValue(item)
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+func nullValue() *pb.Value {
+	return &pb.Value{Kind: &pb.Value_NullValue{NullValue: pb.NullValue_NULL_VALUE}}
+}
+
+func intValue(n int64) *pb.Value {
+	return &pb.Value{Kind: &pb.Value_IntegerValue{IntegerValue: n}}
+}
+
+func uintValue(n uint64) *pb.Value {
+	if n > math.MaxInt64 {
+		return doubleValue(float64(n))
+	}
+	return intValue(int64(n))
+}
+
+func doubleValue(f float64) *pb.Value {
+	return &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: f}}
+}
+
+func (enc *payloadEncoder) structValue(fields map[string]interface{}, path []string) *pb.Value {
+	pbFields := make(map[string]*pb.Value, len(fields))
+	for name, item := range fields {
+		pbFields[name] = enc.value(item, childPath(path, name))
+	}
+	return &pb.Value{Kind: &pb.Value_StructValue{StructValue: &pb.Struct{Fields: pbFields}}}
+}
@@ -0,0 +1,141 @@
+package vector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC)
+	type author struct {
+		Name  string   `json:"name"`
+		Email string   `json:"email,omitempty"`
+		Tags  []string `json:"tags"`
+	}
+	var nilPtr *author
+	n := 7
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{"nil", nil, nil},
+		{"nil pointer", nilPtr, nil},
+		{"string", "hello", "hello"},
+		{"bool", true, true},
+		{"int", 42, int64(42)},
+		{"int8", int8(-3), int64(-3)},
+		{"int32", int32(-7), int64(-7)},
+		{"uint", uint(9), int64(9)},
+		{"uint8", uint8(255), int64(255)},
+		{"uint64", uint64(1 << 40), int64(1 << 40)},
+		{"huge uint64", uint64(math.MaxUint64), float64(math.MaxUint64)},
+		{"float32", float32(0.1), 0.1},
+		{"float64", 2.5, 2.5},
+		{"pointer", &n, int64(7)},
+		{"timestamp", ts, "2024-03-01T12:30:00.0000005Z"},
+		{"timestamp-like string", "2024-03-01T12:30:00Z", "2024-03-01T12:30:00Z"},
+		{"string slice", []string{"go", "rag"}, []interface{}{"go", "rag"}},
+		{"empty slice", []string{}, []interface{}{}},
+		{"mixed list", []interface{}{"a", 1, nil, 1.5}, []interface{}{"a", int64(1), nil, 1.5}},
+		{"array", [2]int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{"nested map", map[string]interface{}{
+			"name":  "Ada",
+			"langs": []interface{}{"en"},
+			"meta":  map[string]interface{}{"age": 36},
+		}, map[string]interface{}{
+			"name":  "Ada",
+			"langs": []interface{}{"en"},
+			"meta":  map[string]interface{}{"age": int64(36)},
+		}},
+		{"typed map", map[string]int{"a": 1}, map[string]interface{}{"a": int64(1)}},
+		{"struct", author{Name: "Ada", Tags: []string{"math"}}, map[string]interface{}{
+			"name": "Ada",
+			"tags": []interface{}{"math"},
+		}},
+		{"list of structs", []author{{Name: "Ada", Tags: nil}}, []interface{}{
+			map[string]interface{}{"name": "Ada", "tags": nil},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fromPbValue(toPbValue(tt.in))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("round trip of %#v: got %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadTimestamps(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC)
+	meta := map[string]interface{}{
+		"updated_at": ts.In(time.FixedZone("CET", 3600)),
+		"content":    "2024-03-01T12:30:00Z",
+		"meta":       map[string]interface{}{"born": ts, "note": "2024-03-01T12:30:00Z"},
+		"history":    []time.Time{ts},
+	}
+	payload := encodePayload(meta)
+	if got := payload["updated_at"].GetStringValue(); got != "2024-03-01T12:30:00.0000005Z" {
+		t.Errorf("expected an RFC 3339 string for the datetime index, got %q", got)
+	}
+
+	got := fromPayload(payload)
+	want := map[string]interface{}{
+		"updated_at": ts,
+		"content":    "2024-03-01T12:30:00Z",
+		"meta":       map[string]interface{}{"born": ts, "note": "2024-03-01T12:30:00Z"},
+		"history":    []interface{}{ts},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip: got %#v, want %#v", got, want)
+	}
+
+	plain := fromPayload(encodePayload(map[string]interface{}{"content": "2024-03-01T12:30:00Z"}))
+	if _, ok := plain[TimestampPathsKey]; ok || len(plain) != 1 {
+		t.Errorf("expected no timestamp bookkeeping without timestamps, got %v", plain)
+	}
+}
+
+func TestToPbValueKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"nil", nil, "*qdrant.Value_NullValue"},
+		{"timestamp", time.Unix(0, 0), "*qdrant.Value_StringValue"},
+		{"slice", []int{1}, "*qdrant.Value_ListValue"},
+		{"map", map[string]string{}, "*qdrant.Value_StructValue"},
+		{"bytes", []byte("hi"), "*qdrant.Value_StringValue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reflect.TypeOf(toPbValue(tt.in).Kind).String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromPayload(t *testing.T) {
+	payload := map[string]*pb.Value{
+		"tags":   toPbValue([]string{"a", "b"}),
+		"author": toPbValue(map[string]interface{}{"name": "Ada"}),
+		"gone":   nil,
+	}
+	want := map[string]interface{}{
+		"tags":   []interface{}{"a", "b"},
+		"author": map[string]interface{}{"name": "Ada"},
+		"gone":   nil,
+	}
+	if got := fromPayload(payload); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
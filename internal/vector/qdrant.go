@@ -6,7 +6,8 @@ import (
 
 	pb "github.com/qdrant/go-client/qdrant"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type QdrantStore struct {
@@ -14,75 +15,166 @@ type QdrantStore struct {
 	pointsClient   pb.PointsClient
 	collectionName string
 	vectorSize     uint64
-}
 
-func NewQdrantStore(addr string, collectionName string, vectorSize uint64) (*QdrantStore, error) {
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return nil, fmt.Errorf("did not connect: %w", err)
-	}
+	// namedVectors lists the vector names of a multi-vector collection; it is
+	// empty for a collection with a single unnamed vector.
+	namedVectors []string
+	// defaultVector receives Point.Vector and serves searches without a
+	// vector name in a multi-vector collection.
+	defaultVector string
+	// spec tunes the collection created and updated by EnsureCollection.
+	spec CollectionSpec
+	// connOpts configure TLS, authentication and timeouts.
+	connOpts connOptions
+}
 
-	client := pb.NewPointsClient(conn)
+// QdrantOption customises a QdrantStore.
+type QdrantOption func(*QdrantStore)
 
-	// Note: We assume the collection might need to be created elsewhere or we can add an EnsureCollection method.
-	// For simplicity, we just return the store.
+// WithNamedVectors makes the collection hold one named vector per name, all
+// of the store's vector size. The first name is the default vector.
+func WithNamedVectors(names ...string) QdrantOption {
+	return func(s *QdrantStore) {
+		s.namedVectors = names
+		if len(names) > 0 {
+			s.defaultVector = names[0]
+		}
+	}
+}
 
-	return &QdrantStore{
-		conn:           conn,
-		pointsClient:   client,
+func NewQdrantStore(addr string, collectionName string, vectorSize uint64, opts ...QdrantOption) (*QdrantStore, error) {
+	s := &QdrantStore{
 		collectionName: collectionName,
 		vectorSize:     vectorSize,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.spec.Validate(); err != nil {
+		return nil, fmt.Errorf("collection %s: %w", collectionName, err)
+	}
+
+	dialOpts, err := s.connOpts.dialOptions()
+	if err != nil {
+		return nil, fmt.Errorf("qdrant connection: %w", err)
+	}
+	conn, err := grpc.NewClient(addr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("did not connect: %w", err)
+	}
+	s.conn = conn
+	s.pointsClient = pb.NewPointsClient(conn)
+	return s, nil
 }
 
 func (s *QdrantStore) Close() error {
 	return s.conn.Close()
 }
 
-// EnsureCollection creates the collection if it doesn't exist.
+// EnsureCollection creates the collection if it doesn't exist, and otherwise
+// verifies that its vector parameters match the store's.
 func (s *QdrantStore) EnsureCollection(ctx context.Context) error {
-	collectionsClient := pb.NewCollectionsClient(s.conn)
-
 	// Check if exists
-	exists, err := collectionsClient.Get(ctx, &pb.GetCollectionInfoRequest{CollectionName: s.collectionName})
-	if err == nil && exists != nil {
-		return nil
+	info, err := s.collectionInfo(ctx)
+	if status.Code(err) == codes.NotFound {
+		return s.createCollection(ctx)
+	}
+	if err != nil {
+		return err
 	}
+	if err := s.checkInfo(info); err != nil {
+		return err
+	}
+	return s.updateCollection(ctx, info)
+}
 
-	// Create
-	_, err = collectionsClient.Create(ctx, &pb.CreateCollection{
-		CollectionName: s.collectionName,
-		VectorsConfig: &pb.VectorsConfig{
-			Config: &pb.VectorsConfig_Params{
-				Params: &pb.VectorParams{
-					Size:     s.vectorSize,
-					Distance: pb.Distance_Cosine,
-				},
-			},
-		},
-	})
+// CheckCollection verifies that the collection exists and was created with
+// the store's vector size and distance. A mismatch is reported as a
+// *MismatchError.
+func (s *QdrantStore) CheckCollection(ctx context.Context) error {
+	info, err := s.collectionInfo(ctx)
+	if err != nil {
+		return err
+	}
+	return s.checkInfo(info)
+}
+
+func (s *QdrantStore) collectionInfo(ctx context.Context) (*pb.CollectionInfo, error) {
+	res, err := pb.NewCollectionsClient(s.conn).Get(ctx, &pb.GetCollectionInfoRequest{CollectionName: s.collectionName})
 	if err != nil {
-		return fmt.Errorf("failed to create collection: %w", err)
+		return nil, fmt.Errorf("failed to get collection %s: %w", s.collectionName, err)
+	}
+	return res.GetResult(), nil
+}
+
+// checkInfo compares the immutable parameters of a collection: its vector
+// layout, sizes and distance.
+func (s *QdrantStore) checkInfo(info *pb.CollectionInfo) error {
+	vectorsConfig := info.GetConfig().GetParams().GetVectorsConfig()
+	if len(s.namedVectors) == 0 {
+		params := vectorsConfig.GetParams()
+		if params == nil {
+			return &MismatchError{Collection: s.collectionName, Field: "layout", Want: "a single unnamed vector", Got: "named vectors"}
+		}
+		return s.checkParams("", params)
+	}
+
+	named := vectorsConfig.GetParamsMap().GetMap()
+	if named == nil {
+		return &MismatchError{Collection: s.collectionName, Field: "layout", Want: "named vectors", Got: "a single unnamed vector"}
+	}
+	for _, name := range s.namedVectors {
+		params, ok := named[name]
+		if !ok {
+			return &MismatchError{Collection: s.collectionName, Field: "names", Want: fmt.Sprintf("a vector named %q", name), Got: "none"}
+		}
+		if err := s.checkParams(name, params); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// checkParams compares the parameters of one (possibly named) vector.
+func (s *QdrantStore) checkParams(name string, params *pb.VectorParams) error {
+	field := func(f string) string {
+		if name == "" {
+			return f
+		}
+		return fmt.Sprintf("%q %s", name, f)
+	}
+	if params.Size != s.vectorSize {
+		return &MismatchError{Collection: s.collectionName, Field: field("size"), Want: fmt.Sprint(s.vectorSize), Got: fmt.Sprint(params.Size)}
+	}
+	if want := s.spec.distance(); params.Distance != want {
+		return &MismatchError{Collection: s.collectionName, Field: field("distance"), Want: want.String(), Got: params.Distance.String()}
+	}
+	return nil
+}
+
+// CheckDimensions implements DimensionChecker.
+func (s *QdrantStore) CheckDimensions(ctx context.Context, size uint64) error {
+	if size != s.vectorSize {
+		return &MismatchError{Collection: s.collectionName, Field: "size", Want: fmt.Sprint(size), Got: fmt.Sprint(s.vectorSize)}
+	}
+	return s.CheckCollection(ctx)
+}
+
 func (s *QdrantStore) Upsert(ctx context.Context, points []*Point) error {
 	qPoints := make([]*pb.PointStruct, len(points))
 	for i, p := range points {
-		// Convert metadata map to Qdrant payload
-		payload := make(map[string]*pb.Value)
-		for k, v := range p.Metadata {
-			payload[k] = toPbValue(v)
+		id, payload, err := toPayload(p)
+		if err != nil {
+			return err
+		}
+		vectors, err := s.pointVectors(p)
+		if err != nil {
+			return err
 		}
 
 		qPoints[i] = &pb.PointStruct{
-			Id: &pb.PointId{
-				PointIdOptions: &pb.PointId_Uuid{Uuid: p.ID},
-			},
-			Vectors: &pb.Vectors{
-				VectorsOptions: &pb.Vectors_Vector{Vector: &pb.Vector{Data: p.Vector}},
-			},
+			Id:      id,
+			Vectors: vectors,
 			Payload: payload,
 		}
 	}
@@ -94,72 +186,141 @@ func (s *QdrantStore) Upsert(ctx context.Context, points []*Point) error {
 	return err
 }
 
+// toPayload converts the ID and metadata of p to a Qdrant point ID and
+// payload.
+func toPayload(p *Point) (*pb.PointId, map[string]*pb.Value, error) {
+	if err := CheckMetadata(p.Metadata); err != nil {
+		return nil, nil, fmt.Errorf("point %s: %w", p.ID, err)
+	}
+	payload := encodePayload(p.Metadata)
+	id, mapped := toPointID(p.ID)
+	if mapped {
+		payload[ExternalIDKey] = toPbValue(p.ID)
+	}
+	return id, payload, nil
+}
+
+// pointVectors maps the vectors of p onto the collection layout.
+func (s *QdrantStore) pointVectors(p *Point) (*pb.Vectors, error) {
+	if len(s.namedVectors) == 0 {
+		if len(p.Vectors) > 0 {
+			return nil, fmt.Errorf("point %s has named vectors but collection %s has none", p.ID, s.collectionName)
+		}
+		return &pb.Vectors{VectorsOptions: &pb.Vectors_Vector{Vector: &pb.Vector{Data: p.Vector}}}, nil
+	}
+
+	named := make(map[string]*pb.Vector, len(p.Vectors)+1)
+	if p.Vector != nil {
+		named[s.defaultVector] = &pb.Vector{Data: p.Vector}
+	}
+	for name, vec := range p.Vectors {
+		if !s.hasVector(name) {
+			return nil, fmt.Errorf("point %s: collection %s has no vector named %q", p.ID, s.collectionName, name)
+		}
+		named[name] = &pb.Vector{Data: vec}
+	}
+	return &pb.Vectors{VectorsOptions: &pb.Vectors_Vectors{Vectors: &pb.NamedVectors{Vectors: named}}}, nil
+}
+
+func (s *QdrantStore) hasVector(name string) bool {
+	for _, n := range s.namedVectors {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *QdrantStore) Search(ctx context.Context, vector []float32, limit int) ([]*ScoredPoint, error) {
-	res, err := s.pointsClient.Search(ctx, &pb.SearchPoints{
+	return s.SearchWithOptions(ctx, vector, SearchOptions{Limit: limit})
+}
+
+func (s *QdrantStore) SearchWithOptions(ctx context.Context, vector []float32, opts SearchOptions) ([]*ScoredPoint, error) {
+	req := &pb.SearchPoints{
 		CollectionName: s.collectionName,
 		Vector:         vector,
-		Limit:          uint64(limit),
+		Limit:          uint64(opts.Limit),
 		WithPayload:    &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}},
-	})
+		ScoreThreshold: opts.ScoreThreshold,
+	}
+	if opts.Offset > 0 {
+		offset := uint64(opts.Offset)
+		req.Offset = &offset
+	}
+	vectorName := opts.VectorName
+	if len(s.namedVectors) > 0 {
+		if vectorName == "" {
+			vectorName = s.defaultVector
+		}
+		if !s.hasVector(vectorName) {
+			return nil, fmt.Errorf("collection %s has no vector named %q", s.collectionName, vectorName)
+		}
+		req.VectorName = &vectorName
+	} else if vectorName != "" {
+		return nil, fmt.Errorf("collection %s has no named vectors", s.collectionName)
+	}
+	if opts.HNSWEf > 0 || opts.Exact {
+		req.Params = &pb.SearchParams{}
+		if opts.HNSWEf > 0 {
+			req.Params.HnswEf = &opts.HNSWEf
+		}
+		if opts.Exact {
+			req.Params.Exact = &opts.Exact
+		}
+	}
+	filter, err := toPbFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	req.Filter = filter
+	if opts.WithVectors {
+		req.WithVectors = &pb.WithVectorsSelector{SelectorOptions: &pb.WithVectorsSelector_Enable{Enable: true}}
+	}
+
+	res, err := s.pointsClient.Search(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
 	results := make([]*ScoredPoint, len(res.Result))
 	for i, r := range res.Result {
-		meta := make(map[string]interface{})
-		for k, v := range r.Payload {
-			meta[k] = fromPbValue(v)
-		}
-
-		var id string
-		if r.Id != nil {
-			if u := r.Id.GetUuid(); u != "" {
-				id = u
-			} else {
-				id = fmt.Sprintf("%d", r.Id.GetNum())
-			}
-		}
-
+		meta := fromPayload(r.Payload)
 		results[i] = &ScoredPoint{
-			ID:       id,
+			ID:       externalID(r.Id, meta),
 			Score:    r.Score,
 			Metadata: meta,
+			Vector:   s.resultVector(r.Vectors, vectorName),
 		}
 	}
 	return results, nil
 }
 
-// Helper to convert Go interface{} to Qdrant Value
-func toPbValue(v interface{}) *pb.Value {
-	switch val := v.(type) {
-	case string:
-		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: val}}
-	case int:
-		return &pb.Value{Kind: &pb.Value_IntegerValue{IntegerValue: int64(val)}}
-	case int64:
-		return &pb.Value{Kind: &pb.Value_IntegerValue{IntegerValue: val}}
-	case float64:
-		return &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: val}}
-	case bool:
-		return &pb.Value{Kind: &pb.Value_BoolValue{BoolValue: val}}
-	default:
-		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: fmt.Sprintf("%v", val)}}
-	}
-}
-
-// Helper to convert Qdrant Value to Go interface{}
-func fromPbValue(v *pb.Value) interface{} {
-	switch k := v.Kind.(type) {
-	case *pb.Value_StringValue:
-		return k.StringValue
-	case *pb.Value_IntegerValue:
-		return k.IntegerValue
-	case *pb.Value_DoubleValue:
-		return k.DoubleValue
-	case *pb.Value_BoolValue:
-		return k.BoolValue
-	default:
+// pointIDString renders a Qdrant point ID as the string used in Point.ID.
+func pointIDString(id *pb.PointId) string {
+	if id == nil {
+		return ""
+	}
+	if u := id.GetUuid(); u != "" {
+		return u
+	}
+	return fmt.Sprintf("%d", id.GetNum())
+}
+
+// resultVector picks the searched vector out of a returned point.
+func (s *QdrantStore) resultVector(v *pb.VectorsOutput, name string) []float32 {
+	if name == "" {
+		return vectorData(v.GetVector())
+	}
+	return vectorData(v.GetVectors().GetVectors()[name])
+}
+
+// vectorData extracts the dense data of a returned vector, if any.
+func vectorData(v *pb.VectorOutput) []float32 {
+	if v == nil {
 		return nil
 	}
+	if d := v.GetDense(); d != nil {
+		return d.GetData()
+	}
+	return v.GetData()
 }
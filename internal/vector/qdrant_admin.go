@@ -0,0 +1,101 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	pb "github.com/qdrant/go-client/qdrant"
+)
+
+// CollectionName returns the collection (or alias) the store operates on.
+func (s *QdrantStore) CollectionName() string {
+	return s.collectionName
+}
+
+// Scroll returns up to limit points in ID order starting at offset, together
+// with the offset of the next page. An empty offset starts at the beginning;
+// an empty next offset means there are no more points. Vectors are not loaded.
+func (s *QdrantStore) Scroll(ctx context.Context, offset string, limit int) ([]*Point, string, error) {
+	req := &pb.ScrollPoints{
+		CollectionName: s.collectionName,
+		WithPayload:    &pb.WithPayloadSelector{SelectorOptions: &pb.WithPayloadSelector_Enable{Enable: true}},
+	}
+	if limit > 0 {
+		l := uint32(limit)
+		req.Limit = &l
+	}
+	if offset != "" {
+		req.Offset = scrollOffset(offset)
+	}
+
+	res, err := s.pointsClient.Scroll(ctx, req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to scroll %s: %w", s.collectionName, err)
+	}
+
+	points := toPoints(res.Result)
+
+	var next string
+	if res.NextPageOffset != nil {
+		next = pointIDString(res.NextPageOffset)
+	}
+	return points, next, nil
+}
+
+// scrollOffset parses an offset previously returned by Scroll.
+func scrollOffset(offset string) *pb.PointId {
+	if n, err := strconv.ParseUint(offset, 10, 64); err == nil {
+		return &pb.PointId{PointIdOptions: &pb.PointId_Num{Num: n}}
+	}
+	return &pb.PointId{PointIdOptions: &pb.PointId_Uuid{Uuid: offset}}
+}
+
+// ResolveAlias returns the collection that name points to. If name is not an
+// alias it is returned unchanged with isAlias set to false.
+func (s *QdrantStore) ResolveAlias(ctx context.Context, name string) (collection string, isAlias bool, err error) {
+	res, err := pb.NewCollectionsClient(s.conn).ListAliases(ctx, &pb.ListAliasesRequest{})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	for _, a := range res.Aliases {
+		if a.AliasName == name {
+			return a.CollectionName, true, nil
+		}
+	}
+	return name, false, nil
+}
+
+// SwitchAlias points alias at collection in a single atomic operation,
+// replacing any previous target.
+func (s *QdrantStore) SwitchAlias(ctx context.Context, alias, collection string) error {
+	_, isAlias, err := s.ResolveAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	var actions []*pb.AliasOperations
+	if isAlias {
+		actions = append(actions, &pb.AliasOperations{
+			Action: &pb.AliasOperations_DeleteAlias{DeleteAlias: &pb.DeleteAlias{AliasName: alias}},
+		})
+	}
+	actions = append(actions, &pb.AliasOperations{
+		Action: &pb.AliasOperations_CreateAlias{CreateAlias: &pb.CreateAlias{CollectionName: collection, AliasName: alias}},
+	})
+
+	_, err = pb.NewCollectionsClient(s.conn).UpdateAliases(ctx, &pb.ChangeAliases{Actions: actions})
+	if err != nil {
+		return fmt.Errorf("failed to point alias %s at %s: %w", alias, collection, err)
+	}
+	return nil
+}
+
+// DeleteCollection drops the named collection.
+func (s *QdrantStore) DeleteCollection(ctx context.Context, name string) error {
+	_, err := pb.NewCollectionsClient(s.conn).Delete(ctx, &pb.DeleteCollection{CollectionName: name})
+	if err != nil {
+		return fmt.Errorf("failed to delete collection %s: %w", name, err)
+	}
+	return nil
+}
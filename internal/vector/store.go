@@ -1,12 +1,18 @@
 package vector
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Point represents a data point in the vector store.
 type Point struct {
 	ID       string                 `json:"id"`
 	Vector   []float32              `json:"vector"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// Vectors holds additional named vectors, e.g. "title" or "code", for
+	// stores configured with multiple vectors per point.
+	Vectors map[string][]float32 `json:"vectors,omitempty"`
 }
 
 // ScoredPoint represents a search result with a similarity score.
@@ -14,6 +20,29 @@ type ScoredPoint struct {
 	ID       string                 `json:"id"`
 	Score    float32                `json:"score"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// Vector is only populated when the search asked for stored vectors.
+	Vector []float32 `json:"vector,omitempty"`
+}
+
+// SearchOptions refines a nearest-neighbour query.
+type SearchOptions struct {
+	// Limit is the maximum number of points to return.
+	Limit int
+	// Offset skips that many of the best matches, for pagination.
+	Offset int
+	// ScoreThreshold drops points scoring worse than the given value.
+	ScoreThreshold *float32
+	// HNSWEf overrides the size of the HNSW candidate list for this query;
+	// larger values trade speed for recall. Zero uses the collection default.
+	HNSWEf uint64
+	// Exact bypasses the index and scores every point.
+	Exact bool
+	// VectorName selects the named vector to search; empty uses the default.
+	VectorName string
+	// WithVectors requests the stored vector of every returned point.
+	WithVectors bool
+	// Filter restricts the search to points with matching payloads.
+	Filter *Filter
 }
 
 // Store defines the interface for interacting with the vector database.
@@ -22,4 +51,43 @@ type Store interface {
 	Upsert(ctx context.Context, points []*Point) error
 	// Search finds the nearest neighbors for the given vector.
 	Search(ctx context.Context, vector []float32, limit int) ([]*ScoredPoint, error)
+	// SearchWithOptions finds the nearest neighbours using the given options.
+	SearchWithOptions(ctx context.Context, vector []float32, opts SearchOptions) ([]*ScoredPoint, error)
+}
+
+// DimensionChecker is implemented by stores that can verify their vectors
+// have the size produced by an embedder.
+type DimensionChecker interface {
+	// CheckDimensions returns a *MismatchError if size does not match.
+	CheckDimensions(ctx context.Context, size uint64) error
+}
+
+// PointLookup is implemented by stores that can read points back, update
+// their payloads and delete them, which lets ingestion skip unchanged and
+// duplicate documents.
+type PointLookup interface {
+	// GetPoints returns the points among ids that exist, with their
+	// vectors if withVectors is set.
+	GetPoints(ctx context.Context, ids []string, withVectors bool) ([]*Point, error)
+	// FindPoints returns up to limit points matching filter, without
+	// vectors. The filtered keys should be indexed.
+	FindPoints(ctx context.Context, filter *Filter, limit int) ([]*Point, error)
+	// UpdatePayloads replaces the payloads of existing points and keeps
+	// their vectors.
+	UpdatePayloads(ctx context.Context, points []*Point) error
+	// DeletePoints removes the points with the given ids, if they exist.
+	DeletePoints(ctx context.Context, ids []string) error
+}
+
+// MismatchError reports a collection whose parameters differ from what the
+// caller expects.
+type MismatchError struct {
+	Collection string
+	Field      string
+	Want       string
+	Got        string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("collection %s: vector %s mismatch: expected %s, found %s", e.Collection, e.Field, e.Want, e.Got)
 }